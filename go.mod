@@ -2,11 +2,15 @@ module github.com/rzajac/testkit
 
 go 1.17
 
-require github.com/stretchr/testify v1.6.2-0.20201103103935-92707c0b2d50
+require (
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/stretchr/testify v1.6.2-0.20201103103935-92707c0b2d50
+	golang.org/x/net v0.17.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.1.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
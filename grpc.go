@@ -0,0 +1,155 @@
+package testkit
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GRPCCode represents gRPC status code.
+type GRPCCode int
+
+// gRPC status codes.
+const (
+	GRPCOK                 GRPCCode = 0
+	GRPCCanceled           GRPCCode = 1
+	GRPCUnknown            GRPCCode = 2
+	GRPCInvalidArgument    GRPCCode = 3
+	GRPCDeadlineExceeded   GRPCCode = 4
+	GRPCNotFound           GRPCCode = 5
+	GRPCAlreadyExists      GRPCCode = 6
+	GRPCPermissionDenied   GRPCCode = 7
+	GRPCResourceExhausted  GRPCCode = 8
+	GRPCFailedPrecondition GRPCCode = 9
+	GRPCAborted            GRPCCode = 10
+	GRPCOutOfRange         GRPCCode = 11
+	GRPCUnimplemented      GRPCCode = 12
+	GRPCInternal           GRPCCode = 13
+	GRPCUnavailable        GRPCCode = 14
+	GRPCDataLoss           GRPCCode = 15
+	GRPCUnauthenticated    GRPCCode = 16
+)
+
+// grpcCodes maps status codes to Connect protocol names and HTTP status
+// codes.
+var grpcCodes = map[GRPCCode]struct {
+	name   string
+	status int
+}{
+	GRPCOK:                 {"ok", http.StatusOK},
+	GRPCCanceled:           {"canceled", 499},
+	GRPCUnknown:            {"unknown", http.StatusInternalServerError},
+	GRPCInvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	GRPCDeadlineExceeded:   {"deadline_exceeded", http.StatusGatewayTimeout},
+	GRPCNotFound:           {"not_found", http.StatusNotFound},
+	GRPCAlreadyExists:      {"already_exists", http.StatusConflict},
+	GRPCPermissionDenied:   {"permission_denied", http.StatusForbidden},
+	GRPCResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	GRPCFailedPrecondition: {"failed_precondition", http.StatusBadRequest},
+	GRPCAborted:            {"aborted", http.StatusConflict},
+	GRPCOutOfRange:         {"out_of_range", http.StatusBadRequest},
+	GRPCUnimplemented:      {"unimplemented", http.StatusNotImplemented},
+	GRPCInternal:           {"internal", http.StatusInternalServerError},
+	GRPCUnavailable:        {"unavailable", http.StatusServiceUnavailable},
+	GRPCDataLoss:           {"data_loss", http.StatusInternalServerError},
+	GRPCUnauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+// String returns status code name as used by Connect protocol.
+func (c GRPCCode) String() string {
+	if v, ok := grpcCodes[c]; ok {
+		return v.name
+	}
+	return fmt.Sprintf("code(%d)", int(c))
+}
+
+// httpStatus returns HTTP status code used by Connect protocol for the
+// status code.
+func (c GRPCCode) httpStatus() int {
+	if v, ok := grpcCodes[c]; ok {
+		return v.status
+	}
+	return http.StatusInternalServerError
+}
+
+// gRPC wire protocols.
+const (
+	grpcProto       = "grpc"           // gRPC over HTTP/2.
+	grpcWebProto    = "grpc-web"       // gRPC-Web.
+	connectProto    = "connect"        // Connect unary.
+	connectStmProto = "connect-stream" // Connect streaming.
+)
+
+// Envelope flags.
+const (
+	grpcFlagCompressed = 0x01 // Message is compressed.
+	grpcFlagEndStream  = 0x02 // Connect end of stream message.
+	grpcFlagTrailer    = 0x80 // gRPC-Web trailers.
+)
+
+// errGRPCFrame is returned when length-prefixed message is truncated.
+var errGRPCFrame = errors.New("truncated grpc message")
+
+// grpcProtocol returns wire protocol for the request Content-Type.
+// Returns empty string for unsupported content types.
+func grpcProtocol(ct string) string {
+	ct = strings.ToLower(strings.TrimSpace(strings.Split(ct, ";")[0]))
+	switch {
+	case ct == "application/grpc-web" || strings.HasPrefix(ct, "application/grpc-web+"):
+		return grpcWebProto
+	case ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+"):
+		return grpcProto
+	case strings.HasPrefix(ct, "application/connect+"):
+		return connectStmProto
+	case ct == "application/proto" || ct == "application/json":
+		return connectProto
+	}
+	return ""
+}
+
+// grpcFrame returns length-prefixed message with flags.
+func grpcFrame(flags byte, msg []byte) []byte {
+	buf := make([]byte, 5, 5+len(msg))
+	buf[0] = flags
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	return append(buf, msg...)
+}
+
+// grpcEnvelope represents length-prefixed message.
+type grpcEnvelope struct {
+	flags byte   // Envelope flags.
+	msg   []byte // The message.
+}
+
+// grpcFrames splits data into length-prefixed messages.
+func grpcFrames(data []byte) ([]grpcEnvelope, error) {
+	var envs []grpcEnvelope
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, errGRPCFrame
+		}
+		size := binary.BigEndian.Uint32(data[1:5])
+		if uint64(len(data)-5) < uint64(size) {
+			return nil, errGRPCFrame
+		}
+		envs = append(envs, grpcEnvelope{flags: data[0], msg: data[5 : 5+size]})
+		data = data[5+size:]
+	}
+	return envs, nil
+}
+
+// grpcPercentEncode percent encodes grpc-message trailer value.
+func grpcPercentEncode(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7E || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
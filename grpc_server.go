@@ -0,0 +1,356 @@
+package testkit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GRPCCall represents gRPC call recorded by GRPCServer.
+type GRPCCall struct {
+	// Full method name, e.g. "/pkg.Service/Method".
+	Method string
+
+	// Wire protocol: "grpc", "grpc-web", "connect" (unary) or
+	// "connect-stream".
+	Protocol string
+
+	// Request headers (metadata).
+	Header http.Header
+
+	// Raw (decompressed) request messages.
+	Messages [][]byte
+}
+
+// GRPCResponse represents a response returned by GRPCServer for a call.
+//
+// GRPCResponse is a builder, every method configures the response and
+// returns it so the calls can be chained.
+//
+//	srv.Rsp("/pkg.Users/Get", msg).Trailer("x-request-id", "1")
+//	srv.Rsp("/pkg.Users/Get").Status(kit.GRPCNotFound, "no user")
+//
+// The response should be configured before the call it answers is made.
+type GRPCResponse struct {
+	code    GRPCCode    // Status code.
+	message string      // Status message.
+	msgs    [][]byte    // Response messages.
+	header  http.Header // Response headers.
+	trailer http.Header // Response trailers.
+	mx      *sync.Mutex // Guards the response fields.
+}
+
+// Status sets status code and message of the response.
+func (rsp *GRPCResponse) Status(code GRPCCode, msg string) *GRPCResponse {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.code = code
+	rsp.message = msg
+	return rsp
+}
+
+// Message adds response message. Add more than one message for server
+// streaming calls.
+func (rsp *GRPCResponse) Message(msg []byte) *GRPCResponse {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.msgs = append(rsp.msgs, msg)
+	return rsp
+}
+
+// Header adds response header (metadata).
+func (rsp *GRPCResponse) Header(key, value string) *GRPCResponse {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.header.Add(key, value)
+	return rsp
+}
+
+// Trailer adds response trailer (metadata).
+func (rsp *GRPCResponse) Trailer(key, value string) *GRPCResponse {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.trailer.Add(key, value)
+	return rsp
+}
+
+// GRPCServer represents gRPC test server built on top of HTTPServer.
+//
+// The server accepts gRPC over HTTP/2, gRPC-Web and Connect calls without
+// generated server stubs. Request messages are recorded as raw bytes and
+// calls are answered with responses queued per method with Rsp() method,
+// in the order they were added. Calls to methods without queued responses
+// are reported with t.Errorf() and answered with GRPCUnimplemented status.
+// The HTTP requests are recorded by the underlying HTTPServer.
+type GRPCServer struct {
+	*HTTPServer
+	mx    sync.Mutex                 // Guards fields below.
+	calls []GRPCCall                 // Recorded calls.
+	rsps  map[string][]*GRPCResponse // Queued responses by method.
+}
+
+// NewGRPCServer returns new instance of GRPCServer configured with
+// HTTPServer options. The server always has HTTP/2 enabled. The server
+// will fail the test if during cleanup there are unused responses.
+func NewGRPCServer(t T, opts ...HTTPServerOption) *GRPCServer {
+	t.Helper()
+	gs := &GRPCServer{rsps: make(map[string][]*GRPCResponse)}
+	opts = append(opts, WithHTTP2(), func(tst *HTTPServer) { tst.fallback = gs })
+
+	t.Cleanup(func() {
+		t.Helper()
+		gs.mx.Lock()
+		defer gs.mx.Unlock()
+		methods := make([]string, 0, len(gs.rsps))
+		for method := range gs.rsps {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			if cnt := len(gs.rsps[method]); cnt > 0 {
+				t.Errorf("grpc method %s has %d unused responses", method, cnt)
+			}
+		}
+	})
+
+	gs.HTTPServer = NewHTTPServer(t, opts...)
+	return gs
+}
+
+// Rsp queues response with GRPCOK status and messages msgs for the full
+// method name, e.g. "/pkg.Service/Method". Use returned GRPCResponse to
+// configure status, headers and trailers.
+func (gs *GRPCServer) Rsp(method string, msgs ...[]byte) *GRPCResponse {
+	gs.mx.Lock()
+	defer gs.mx.Unlock()
+	rsp := &GRPCResponse{
+		msgs:    msgs,
+		header:  make(http.Header),
+		trailer: make(http.Header),
+		mx:      &gs.mx,
+	}
+	gs.rsps[method] = append(gs.rsps[method], rsp)
+	return rsp
+}
+
+// Calls returns all recorded calls.
+func (gs *GRPCServer) Calls() []GRPCCall {
+	gs.mx.Lock()
+	defer gs.mx.Unlock()
+	return append([]GRPCCall{}, gs.calls...)
+}
+
+// Call returns the nth recorded call. Calls t.Fatal() if n is greater
+// than number or recorded calls.
+func (gs *GRPCServer) Call(n int) GRPCCall {
+	gs.t.Helper()
+	gs.mx.Lock()
+	defer gs.mx.Unlock()
+	if n < 0 || n >= len(gs.calls) {
+		gs.t.Fatalf("no grpc call with index %d recorded", n)
+		return GRPCCall{}
+	}
+	return gs.calls[n]
+}
+
+// MethodCalls returns recorded calls to the full method name.
+func (gs *GRPCServer) MethodCalls(method string) []GRPCCall {
+	gs.mx.Lock()
+	defer gs.mx.Unlock()
+	var calls []GRPCCall
+	for _, call := range gs.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// ServeHTTP implements http.Handler recording the call and answering it
+// with the next queued response.
+func (gs *GRPCServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	proto := grpcProtocol(req.Header.Get("Content-Type"))
+	if proto == "" || req.Method != http.MethodPost {
+		gs.t.Errorf(
+			"invalid grpc request %s %s Content-Type: %q",
+			req.Method,
+			req.URL,
+			req.Header.Get("Content-Type"),
+		)
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	call := GRPCCall{
+		Method:   req.URL.Path,
+		Protocol: proto,
+		Header:   req.Header.Clone(),
+	}
+	msgs, err := gs.messages(proto, req)
+	if err != nil {
+		gs.t.Errorf("grpc call %s: %s", call.Method, err)
+		gs.mx.Lock()
+		gs.calls = append(gs.calls, call)
+		gs.mx.Unlock()
+		writeGRPC(w, proto, req, &GRPCResponse{
+			code:    GRPCInternal,
+			message: err.Error(),
+		})
+		return
+	}
+	call.Messages = msgs
+
+	gs.mx.Lock()
+	gs.calls = append(gs.calls, call)
+	var rsp *GRPCResponse
+	if queue := gs.rsps[call.Method]; len(queue) > 0 {
+		rsp, gs.rsps[call.Method] = queue[0], queue[1:]
+		c := *rsp
+		rsp = &c
+	}
+	gs.mx.Unlock()
+
+	if rsp == nil {
+		gs.t.Errorf("no grpc response for method %s", call.Method)
+		rsp = &GRPCResponse{
+			code:    GRPCUnimplemented,
+			message: "no response for method " + call.Method,
+		}
+	}
+	writeGRPC(w, proto, req, rsp)
+}
+
+// messages reads and decompresses request messages.
+func (gs *GRPCServer) messages(proto string, req *http.Request) ([][]byte, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if proto == connectProto {
+		enc := req.Header.Get("Content-Encoding")
+		if enc == "" || enc == "identity" {
+			return [][]byte{body}, nil
+		}
+		msg, err := decompress(enc, body)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{msg}, nil
+	}
+
+	enc := req.Header.Get("Grpc-Encoding")
+	if proto == connectStmProto {
+		enc = req.Header.Get("Connect-Content-Encoding")
+	}
+	envs, err := grpcFrames(body)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([][]byte, 0, len(envs))
+	for _, env := range envs {
+		if proto == connectStmProto && env.flags&grpcFlagEndStream != 0 {
+			continue
+		}
+		msg := env.msg
+		if env.flags&grpcFlagCompressed != 0 {
+			if msg, err = decompress(enc, msg); err != nil {
+				return nil, err
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// writeGRPC writes response rsp using wire protocol proto.
+func writeGRPC(w http.ResponseWriter, proto string, req *http.Request, rsp *GRPCResponse) {
+	hdr := w.Header()
+	for key, values := range rsp.header {
+		hdr[key] = append(hdr[key], values...)
+	}
+
+	switch proto {
+	case grpcProto:
+		hdr.Set("Content-Type", req.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+		for _, msg := range rsp.msgs {
+			_, _ = w.Write(grpcFrame(0, msg))
+		}
+		for key, values := range rsp.trailer {
+			hdr[http.TrailerPrefix+key] = values
+		}
+		hdr.Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(int(rsp.code)))
+		if rsp.message != "" {
+			hdr.Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(rsp.message))
+		}
+
+	case grpcWebProto:
+		hdr.Set("Content-Type", req.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+		for _, msg := range rsp.msgs {
+			_, _ = w.Write(grpcFrame(0, msg))
+		}
+		var b strings.Builder
+		b.WriteString("grpc-status: " + strconv.Itoa(int(rsp.code)) + "\r\n")
+		if rsp.message != "" {
+			b.WriteString("grpc-message: " + grpcPercentEncode(rsp.message) + "\r\n")
+		}
+		for _, key := range sortedKeys(rsp.trailer) {
+			for _, value := range rsp.trailer[key] {
+				b.WriteString(strings.ToLower(key) + ": " + value + "\r\n")
+			}
+		}
+		_, _ = w.Write(grpcFrame(grpcFlagTrailer, []byte(b.String())))
+
+	case connectProto:
+		for key, values := range rsp.trailer {
+			hdr[http.CanonicalHeaderKey("Trailer-"+key)] = values
+		}
+		if rsp.code != GRPCOK {
+			hdr.Set("Content-Type", "application/json")
+			w.WriteHeader(rsp.code.httpStatus())
+			_ = json.NewEncoder(w).Encode(connectError{
+				Code:    rsp.code.String(),
+				Message: rsp.message,
+			})
+			return
+		}
+		hdr.Set("Content-Type", req.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+		if len(rsp.msgs) > 0 {
+			_, _ = w.Write(rsp.msgs[0])
+		}
+
+	case connectStmProto:
+		hdr.Set("Content-Type", req.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+		for _, msg := range rsp.msgs {
+			_, _ = w.Write(grpcFrame(0, msg))
+		}
+		end := connectEndStream{Metadata: rsp.trailer}
+		if rsp.code != GRPCOK {
+			end.Error = &connectError{
+				Code:    rsp.code.String(),
+				Message: rsp.message,
+			}
+		}
+		data, _ := json.Marshal(end)
+		_, _ = w.Write(grpcFrame(grpcFlagEndStream, data))
+	}
+}
+
+// connectError represents Connect protocol error.
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// connectEndStream represents Connect protocol end of stream message.
+type connectEndStream struct {
+	Error    *connectError `json:"error,omitempty"`
+	Metadata http.Header   `json:"metadata,omitempty"`
+}
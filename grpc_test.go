@@ -0,0 +1,337 @@
+package testkit_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_GRPCServer(t *testing.T) {
+	// --- Given ---
+	srv := kit.NewGRPCServer(t)
+	srv.Rsp("/pkg.Users/Get", []byte("user")).
+		Header("x-server", "fake").
+		Trailer("x-request-id", "1")
+
+	body := grpcFrame(0, []byte("req"))
+	req, err := http.NewRequest(http.MethodPost, srv.URL()+"/pkg.Users/Get", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	// --- When ---
+	rsp, err := srv.Client().Do(req)
+
+	// --- Then ---
+	require.NoError(t, err)
+	assert.Exactly(t, 2, rsp.ProtoMajor)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "application/grpc", rsp.Header.Get("Content-Type"))
+	assert.Exactly(t, "fake", rsp.Header.Get("X-Server"))
+	assert.Exactly(t, [][]byte{[]byte("user")}, grpcMessages(t, getResponseBody(t, rsp)))
+	assert.Exactly(t, "0", rsp.Trailer.Get("Grpc-Status"))
+	assert.Exactly(t, "1", rsp.Trailer.Get("X-Request-Id"))
+
+	calls := srv.Calls()
+	require.Len(t, calls, 1)
+	assert.Exactly(t, "/pkg.Users/Get", calls[0].Method)
+	assert.Exactly(t, "grpc", calls[0].Protocol)
+	assert.Exactly(t, [][]byte{[]byte("req")}, calls[0].Messages)
+	assert.Exactly(t, "trailers", srv.Call(0).Header.Get("TE"))
+	assert.Len(t, srv.MethodCalls("/pkg.Users/Get"), 1)
+	assert.Len(t, srv.MethodCalls("/pkg.Users/List"), 0)
+	assert.Exactly(t, 1, srv.ReqCount())
+}
+
+func Test_GRPCServer_Status(t *testing.T) {
+	// --- Given ---
+	srv := kit.NewGRPCServer(t)
+	srv.Rsp("/pkg.Users/Get").Status(kit.GRPCNotFound, "no user 100%")
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL()+"/pkg.Users/Get", bytes.NewReader(grpcFrame(0, nil)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc+proto")
+
+	// --- When ---
+	rsp, err := srv.Client().Do(req)
+
+	// --- Then ---
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "application/grpc+proto", rsp.Header.Get("Content-Type"))
+	assert.Len(t, getResponseBody(t, rsp), 0)
+	assert.Exactly(t, "5", rsp.Trailer.Get("Grpc-Status"))
+	assert.Exactly(t, "no user 100%25", rsp.Trailer.Get("Grpc-Message"))
+	assert.Exactly(t, [][]byte{{}}, srv.Call(0).Messages)
+}
+
+func Test_GRPCServer_streaming(t *testing.T) {
+	// --- Given ---
+	srv := kit.NewGRPCServer(t)
+	srv.Rsp("/pkg.Users/List", []byte("u1")).Message([]byte("u2"))
+	srv.Rsp("/pkg.Users/List")
+
+	var buf bytes.Buffer
+	buf.Write(grpcFrame(0, []byte("a")))
+	buf.Write(grpcFrame(1, gzipBytes(t, []byte("b"))))
+
+	// --- When ---
+	var got [][][]byte
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodPost, srv.URL()+"/pkg.Users/List", bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("Grpc-Encoding", "gzip")
+		rsp, err := srv.Client().Do(req)
+		require.NoError(t, err)
+		got = append(got, grpcMessages(t, getResponseBody(t, rsp)))
+	}
+
+	// --- Then ---
+	assert.Exactly(t, [][]byte{[]byte("u1"), []byte("u2")}, got[0])
+	assert.Nil(t, got[1])
+	assert.Exactly(t, [][]byte{[]byte("a"), []byte("b")}, srv.Call(0).Messages)
+	assert.Len(t, srv.MethodCalls("/pkg.Users/List"), 2)
+}
+
+func Test_GRPCServer_gRPCWeb(t *testing.T) {
+	// --- Given ---
+	srv := kit.NewGRPCServer(t)
+	srv.Rsp("/pkg.Users/Get", []byte("user")).
+		Status(kit.GRPCAborted, "retry").
+		Trailer("x-request-id", "1")
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL()+"/pkg.Users/Get", bytes.NewReader(grpcFrame(0, []byte("req"))))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+
+	// --- When ---
+	rsp, err := http.DefaultClient.Do(req)
+
+	// --- Then ---
+	require.NoError(t, err)
+	assert.Exactly(t, 1, rsp.ProtoMajor)
+	assert.Exactly(t, "application/grpc-web+proto", rsp.Header.Get("Content-Type"))
+	frames := grpcMessages(t, getResponseBody(t, rsp))
+	require.Len(t, frames, 2)
+	assert.Exactly(t, "user", string(frames[0]))
+	exp := "grpc-status: 10\r\ngrpc-message: retry\r\nx-request-id: 1\r\n"
+	assert.Exactly(t, exp, string(frames[1]))
+	assert.Exactly(t, "grpc-web", srv.Call(0).Protocol)
+}
+
+func Test_GRPCServer_Connect(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		rsp    func(srv *kit.GRPCServer)
+		status int
+		ct     string
+		body   string
+	}{
+		{
+			"ok",
+			func(srv *kit.GRPCServer) {
+				srv.Rsp("/pkg.Users/Get", []byte(`{"id":1}`)).Trailer("x-request-id", "1")
+			},
+			http.StatusOK,
+			"application/json",
+			`{"id":1}`,
+		},
+		{
+			"error",
+			func(srv *kit.GRPCServer) {
+				srv.Rsp("/pkg.Users/Get").
+					Status(kit.GRPCPermissionDenied, "denied").
+					Trailer("x-request-id", "1")
+			},
+			http.StatusForbidden,
+			"application/json",
+			`{"code":"permission_denied","message":"denied"}` + "\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			srv := kit.NewGRPCServer(t)
+			tc.rsp(srv)
+
+			// --- When ---
+			rsp, err := http.Post(
+				srv.URL()+"/pkg.Users/Get",
+				"application/json",
+				bytes.NewReader([]byte(`{"id":1}`)),
+			)
+
+			// --- Then ---
+			require.NoError(t, err)
+			assert.Exactly(t, tc.status, rsp.StatusCode)
+			assert.Exactly(t, tc.ct, rsp.Header.Get("Content-Type"))
+			assert.Exactly(t, "1", rsp.Header.Get("Trailer-X-Request-Id"))
+			assert.Exactly(t, tc.body, string(getResponseBody(t, rsp)))
+
+			call := srv.Call(0)
+			assert.Exactly(t, "connect", call.Protocol)
+			assert.Exactly(t, [][]byte{[]byte(`{"id":1}`)}, call.Messages)
+		})
+	}
+}
+
+func Test_GRPCServer_ConnectStream(t *testing.T) {
+	// --- Given ---
+	srv := kit.NewGRPCServer(t)
+	srv.Rsp("/pkg.Users/List", []byte("u1")).
+		Status(kit.GRPCUnavailable, "down").
+		Trailer("x-request-id", "1")
+
+	var buf bytes.Buffer
+	buf.Write(grpcFrame(0, []byte("req")))
+	buf.Write(grpcFrame(2, []byte("{}")))
+
+	// --- When ---
+	rsp, err := http.Post(srv.URL()+"/pkg.Users/List", "application/connect+proto", &buf)
+
+	// --- Then ---
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "application/connect+proto", rsp.Header.Get("Content-Type"))
+	frames := grpcMessages(t, getResponseBody(t, rsp))
+	require.Len(t, frames, 2)
+	assert.Exactly(t, "u1", string(frames[0]))
+	exp := `{"error":{"code":"unavailable","message":"down"},"metadata":{"X-Request-Id":["1"]}}`
+	assert.Exactly(t, exp, string(frames[1]))
+
+	call := srv.Call(0)
+	assert.Exactly(t, "connect-stream", call.Protocol)
+	assert.Exactly(t, [][]byte{[]byte("req")}, call.Messages)
+}
+
+func Test_GRPCServer_noResponse(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Errorf", "no grpc response for method %s", "/pkg.Users/Get")
+
+	srv := kit.NewGRPCServer(mck)
+	req, err := http.NewRequest(http.MethodPost, srv.URL()+"/pkg.Users/Get", bytes.NewReader(grpcFrame(0, nil)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc")
+
+	// --- When ---
+	rsp, err := srv.Client().Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	getResponseBody(t, rsp)
+	assert.Exactly(t, "12", rsp.Trailer.Get("Grpc-Status"))
+	assert.Exactly(t, "no response for method /pkg.Users/Get", rsp.Trailer.Get("Grpc-Message"))
+}
+
+func Test_GRPCServer_invalidRequest(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"invalid grpc request %s %s Content-Type: %q",
+		http.MethodPost,
+		mock.Anything,
+		"text/plain",
+	)
+
+	srv := kit.NewGRPCServer(mck)
+
+	// --- When ---
+	rsp, err := http.Post(srv.URL()+"/pkg.Users/Get", "text/plain", nil)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusUnsupportedMediaType, rsp.StatusCode)
+}
+
+func Test_GRPCServer_truncatedMessage(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Errorf", "grpc call %s: %s", "/pkg.Users/Get", mock.Anything)
+
+	srv := kit.NewGRPCServer(mck)
+	req, err := http.NewRequest(http.MethodPost, srv.URL()+"/pkg.Users/Get", bytes.NewReader([]byte{0, 0, 0, 0, 9, 1}))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc")
+
+	// --- When ---
+	rsp, err := srv.Client().Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	getResponseBody(t, rsp)
+	assert.Exactly(t, "13", rsp.Trailer.Get("Grpc-Status"))
+	assert.Exactly(t, "truncated grpc message", rsp.Trailer.Get("Grpc-Message"))
+	assert.Nil(t, srv.Call(0).Messages)
+}
+
+func Test_GRPCServer_unusedResponses(t *testing.T) {
+	// --- Given ---
+	var cleanups []func()
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything).Run(func(args mock.Arguments) {
+		cleanups = append(cleanups, args.Get(0).(func()))
+	})
+	mck.On("Helper")
+	mck.On("Errorf", "grpc method %s has %d unused responses", "/pkg.Users/Get", 2)
+
+	srv := kit.NewGRPCServer(mck)
+	srv.Rsp("/pkg.Users/Get")
+	srv.Rsp("/pkg.Users/Get")
+
+	// --- When ---
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+}
+
+func Test_GRPCCode_String(t *testing.T) {
+	assert.Exactly(t, "ok", kit.GRPCOK.String())
+	assert.Exactly(t, "unauthenticated", kit.GRPCUnauthenticated.String())
+	assert.Exactly(t, "code(99)", kit.GRPCCode(99).String())
+}
+
+// grpcFrame returns length-prefixed message with flags.
+func grpcFrame(flags byte, msg []byte) []byte {
+	buf := make([]byte, 5, 5+len(msg))
+	buf[0] = flags
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	return append(buf, msg...)
+}
+
+// grpcMessages splits data into length-prefixed messages.
+func grpcMessages(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var msgs [][]byte
+	for len(data) > 0 {
+		require.GreaterOrEqual(t, len(data), 5)
+		size := int(binary.BigEndian.Uint32(data[1:5]))
+		require.GreaterOrEqual(t, len(data)-5, size)
+		msgs = append(msgs, data[5:5+size])
+		data = data[5+size:]
+	}
+	return msgs
+}
@@ -0,0 +1,318 @@
+package testkit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2TokenPath is the path of the fake OAuth2 token endpoint.
+const OAuth2TokenPath = "/oauth2/token"
+
+// AuthChecker represents request authentication checker.
+type AuthChecker struct {
+	scheme string                                     // Authentication scheme.
+	check  func(req *http.Request, body []byte) error // Checking function.
+}
+
+// NewAuthChecker returns new AuthChecker for authentication scheme (used
+// in WWW-Authenticate header) with checking function returning error when
+// the request is not authenticated. The fn may be called concurrently and
+// may call HTTPServer methods.
+func NewAuthChecker(scheme string, fn func(req *http.Request, body []byte) error) AuthChecker {
+	return AuthChecker{scheme: scheme, check: fn}
+}
+
+// BasicAuth returns AuthChecker accepting requests with Basic
+// authentication credentials user and pass.
+func BasicAuth(user, pass string) AuthChecker {
+	return NewAuthChecker(`Basic realm="testkit"`, func(req *http.Request, _ []byte) error {
+		u, p, ok := req.BasicAuth()
+		if !ok {
+			return errors.New("missing basic credentials")
+		}
+		if !secureEqual(u, user) || !secureEqual(p, pass) {
+			return errors.New("invalid basic credentials")
+		}
+		return nil
+	})
+}
+
+// BearerAuth returns AuthChecker accepting requests with one of the
+// bearer tokens.
+func BearerAuth(tokens ...string) AuthChecker {
+	return NewAuthChecker("Bearer", func(req *http.Request, _ []byte) error {
+		tok, ok := bearerToken(req)
+		if !ok {
+			return errors.New("missing bearer token")
+		}
+		for _, t := range tokens {
+			if secureEqual(tok, t) {
+				return nil
+			}
+		}
+		return errors.New("invalid bearer token")
+	})
+}
+
+// HMACAuth returns AuthChecker accepting requests with header set to
+// prefix followed by hex encoded HMAC-SHA256 of the request body signed
+// with secret. For example HMACAuth("X-Hub-Signature-256", "sha256=", key).
+func HMACAuth(header, prefix string, secret []byte) AuthChecker {
+	return NewAuthChecker("HMAC", func(req *http.Request, body []byte) error {
+		sig := req.Header.Get(header)
+		if sig == "" {
+			return fmt.Errorf("missing %s header", header)
+		}
+		if !secureEqual(sig, prefix+HMACSHA256(secret, body)) {
+			return fmt.Errorf("invalid %s signature", header)
+		}
+		return nil
+	})
+}
+
+// HMACSHA256 returns hex encoded HMAC-SHA256 of msg signed with secret.
+func HMACSHA256(secret, msg []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(msg)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequireAuth makes HTTPServer reject requests not accepted by any of the
+// checkers with status 401 and WWW-Authenticate header. The rejected
+// requests don't use added responses and are not counted as expected
+// requests, use AuthError to check why they were rejected. Requests to the
+// OAuth2 token endpoint are not checked. The checkers run without the
+// server lock held, so they may call HTTPServer methods.
+func (tst *HTTPServer) RequireAuth(checkers ...AuthChecker) *HTTPServer {
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
+	tst.auth = append(tst.auth, checkers...)
+	return tst
+}
+
+// AuthError returns the reason the nth request was rejected as not
+// authenticated or nil if it was not rejected. Calls t.Fatal() if n is
+// greater than number or received requests.
+func (tst *HTTPServer) AuthError(n int) error {
+	tst.t.Helper()
+	rec, _ := tst.record(n)
+	return rec.den
+}
+
+// authenticate returns error if none of the checkers accepts the request.
+// Returns nil if there are no checkers. The checkers may call HTTPServer
+// methods so it must be called without the lock held.
+func authenticate(checkers []AuthChecker, req *http.Request, body []byte) error {
+	if len(checkers) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, ac := range checkers {
+		err := ac.check(req, body)
+		if err == nil {
+			return nil
+		}
+		msgs = append(msgs, err.Error())
+	}
+	return errors.New(strings.Join(msgs, ", "))
+}
+
+// deny writes status 401 with WWW-Authenticate headers of the checkers.
+func (tst *HTTPServer) deny(w http.ResponseWriter) {
+	tst.mx.Lock()
+	for _, ac := range tst.auth {
+		w.Header().Add("WWW-Authenticate", ac.scheme)
+	}
+	tst.mx.Unlock()
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// OAuth2Token represents token issued by the fake OAuth2 token endpoint.
+type OAuth2Token struct {
+	AccessToken  string    // Access token.
+	RefreshToken string    // Refresh token.
+	GrantType    string    // Grant type the token was issued for.
+	Issued       time.Time // Issue time.
+	Expires      time.Time // Access token expiration time.
+}
+
+// OAuth2 represents fake OAuth2 token endpoint supporting client
+// credentials and refresh token grants.
+type OAuth2 struct {
+	id     string        // Client ID.
+	secret string        // Client secret.
+	url    string        // Token endpoint URL.
+	mx     sync.Mutex    // Guards fields below.
+	ttl    time.Duration // Access token lifetime.
+	tokens []OAuth2Token // Issued tokens.
+}
+
+// OAuth2 mounts fake OAuth2 token endpoint at OAuth2TokenPath accepting
+// client credentials id and secret (with Basic authentication or in the
+// form) and returns it. The endpoint issues access tokens valid for one
+// minute, refresh tokens can be used only once. Use Checker with
+// RequireAuth to accept requests with valid access tokens.
+func (tst *HTTPServer) OAuth2(id, secret string) *OAuth2 {
+	oa := &OAuth2{
+		id:     id,
+		secret: secret,
+		url:    tst.URL() + OAuth2TokenPath,
+		ttl:    time.Minute,
+	}
+	tst.Route(MatchPath(OAuth2TokenPath)).Handler(oa)
+	tst.mx.Lock()
+	tst.public = OAuth2TokenPath
+	tst.mx.Unlock()
+	return oa
+}
+
+// TTL sets access token lifetime.
+func (oa *OAuth2) TTL(d time.Duration) *OAuth2 {
+	oa.mx.Lock()
+	defer oa.mx.Unlock()
+	oa.ttl = d
+	return oa
+}
+
+// URL returns token endpoint URL.
+func (oa *OAuth2) URL() string { return oa.url }
+
+// Tokens returns issued tokens.
+func (oa *OAuth2) Tokens() []OAuth2Token {
+	oa.mx.Lock()
+	defer oa.mx.Unlock()
+	return append([]OAuth2Token{}, oa.tokens...)
+}
+
+// Expire expires all issued access tokens.
+func (oa *OAuth2) Expire() {
+	oa.mx.Lock()
+	defer oa.mx.Unlock()
+	now := time.Now()
+	for i := range oa.tokens {
+		oa.tokens[i].Expires = now
+	}
+}
+
+// Checker returns AuthChecker accepting requests with not expired access
+// tokens issued by the endpoint.
+func (oa *OAuth2) Checker() AuthChecker {
+	return NewAuthChecker("Bearer", func(req *http.Request, _ []byte) error {
+		tok, ok := bearerToken(req)
+		if !ok {
+			return errors.New("missing bearer token")
+		}
+		oa.mx.Lock()
+		defer oa.mx.Unlock()
+		for _, t := range oa.tokens {
+			if t.AccessToken != tok {
+				continue
+			}
+			if !time.Now().Before(t.Expires) {
+				return errors.New("expired access token")
+			}
+			return nil
+		}
+		return errors.New("invalid access token")
+	})
+}
+
+// ServeHTTP implements http.Handler.
+func (oa *OAuth2) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		oauth2Error(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	id, secret, ok := req.BasicAuth()
+	if !ok {
+		id, secret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+	if !secureEqual(id, oa.id) || !secureEqual(secret, oa.secret) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="testkit"`)
+		oauth2Error(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	grant := req.PostForm.Get("grant_type")
+	tok, ttl, code := oa.issue(grant, req.PostForm.Get("refresh_token"))
+	if code != "" {
+		oauth2Error(w, http.StatusBadRequest, code)
+		return
+	}
+	resWrite(w, http.StatusOK, map[string]interface{}{
+		"access_token":  tok.AccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(math.Ceil(ttl.Seconds())),
+		"refresh_token": tok.RefreshToken,
+	})
+}
+
+// issue issues token for the grant type, refresh is the refresh token for
+// "refresh_token" grant. Returns the token and its lifetime or OAuth2
+// error code if the token cannot be issued.
+func (oa *OAuth2) issue(grant, refresh string) (OAuth2Token, time.Duration, string) {
+	oa.mx.Lock()
+	defer oa.mx.Unlock()
+
+	switch grant {
+	case "client_credentials":
+	case "refresh_token":
+		idx := -1
+		for i, t := range oa.tokens {
+			if refresh != "" && t.RefreshToken == refresh {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return OAuth2Token{}, 0, "invalid_grant"
+		}
+		oa.tokens[idx].RefreshToken = ""
+	default:
+		return OAuth2Token{}, 0, "unsupported_grant_type"
+	}
+
+	now := time.Now()
+	tok := OAuth2Token{
+		AccessToken:  RandStr(32),
+		RefreshToken: RandStr(32),
+		GrantType:    grant,
+		Issued:       now,
+		Expires:      now.Add(oa.ttl),
+	}
+	oa.tokens = append(oa.tokens, tok)
+	return tok, oa.ttl, ""
+}
+
+// oauth2Error writes OAuth2 error response.
+func oauth2Error(w http.ResponseWriter, status int, code string) {
+	resWrite(w, status, map[string]string{"error": code})
+}
+
+// bearerToken returns bearer token from the Authorization header.
+func bearerToken(req *http.Request) (string, bool) {
+	hdr := req.Header.Get("Authorization")
+	if len(hdr) < 7 || !strings.EqualFold(hdr[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(hdr[7:]), true
+}
+
+// secureEqual compares strings in constant time.
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,254 @@
+package testkit_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_RequireAuth_basic(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck).RequireAuth(kit.BasicAuth("user", "pass"))
+	srv.Rsp(http.StatusOK, []byte("ok"))
+
+	req0, err := http.NewRequest(http.MethodGet, srv.URL(), nil)
+	require.NoError(t, err)
+	req0.SetBasicAuth("user", "bad")
+	req1, err := http.NewRequest(http.MethodGet, srv.URL(), nil)
+	require.NoError(t, err)
+	req1.SetBasicAuth("user", "pass")
+
+	// --- When ---
+	rsp0, err0 := http.DefaultClient.Do(req0)
+	rsp1, err1 := http.DefaultClient.Do(req1)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusUnauthorized, rsp0.StatusCode)
+	assert.Exactly(t, `Basic realm="testkit"`, rsp0.Header.Get("WWW-Authenticate"))
+	assert.EqualError(t, srv.AuthError(0), "invalid basic credentials")
+
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusOK, rsp1.StatusCode)
+	assert.Exactly(t, "ok", string(getResponseBody(t, rsp1)))
+	assert.NoError(t, srv.AuthError(1))
+}
+
+func Test_HTTPServer_RequireAuth_anyChecker(t *testing.T) {
+	// --- Given ---
+	key := []byte("secret")
+	body := []byte(`{"action":"opened"}`)
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck).RequireAuth(
+		kit.BearerAuth("token"),
+		kit.HMACAuth("X-Hub-Signature-256", "sha256=", key),
+	)
+	srv.Rsp(http.StatusOK, nil).Rsp(http.StatusOK, nil)
+
+	req0, err := http.NewRequest(http.MethodPost, srv.URL(), bytes.NewReader(body))
+	require.NoError(t, err)
+	req0.Header.Set("X-Hub-Signature-256", "sha256="+kit.HMACSHA256(key, body))
+	req1, err := http.NewRequest(http.MethodPost, srv.URL(), bytes.NewReader(body))
+	require.NoError(t, err)
+	req1.Header.Set("Authorization", "Bearer token")
+	req2, err := http.NewRequest(http.MethodPost, srv.URL(), bytes.NewReader(body))
+	require.NoError(t, err)
+	req2.Header.Set("X-Hub-Signature-256", "sha256=abc")
+
+	// --- When ---
+	rsp0, err0 := http.DefaultClient.Do(req0)
+	rsp1, err1 := http.DefaultClient.Do(req1)
+	rsp2, err2 := http.DefaultClient.Do(req2)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusOK, rsp1.StatusCode)
+	require.NoError(t, err2)
+	assert.Exactly(t, http.StatusUnauthorized, rsp2.StatusCode)
+	assert.Exactly(t, []string{"Bearer", "HMAC"}, rsp2.Header.Values("WWW-Authenticate"))
+	exp := "missing bearer token, invalid X-Hub-Signature-256 signature"
+	assert.EqualError(t, srv.AuthError(2), exp)
+}
+
+func Test_HTTPServer_RequireAuth_checkerCallsServer(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.RequireAuth(kit.NewAuthChecker("Test", func(*http.Request, []byte) error {
+		if srv.ReqCount() > 0 {
+			return errors.New("only one request allowed")
+		}
+		return nil
+	}))
+	srv.Rsp(http.StatusOK, nil)
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL())
+	rsp1, err1 := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusUnauthorized, rsp1.StatusCode)
+	assert.EqualError(t, srv.AuthError(1), "only one request allowed")
+}
+
+// oauth2Token requests token from the endpoint at u with form values.
+func oauth2Token(t *testing.T, u string, form url.Values) (int, map[string]interface{}) {
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("id", "secret")
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var m map[string]interface{}
+	kit.FromJSON(t, getResponseBody(t, rsp), &m)
+	return rsp.StatusCode, m
+}
+
+// getWithToken sends GET request to URL u with bearer token.
+func getWithToken(t *testing.T, u, token string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return rsp
+}
+
+func Test_HTTPServer_OAuth2(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	oa := srv.OAuth2("id", "secret")
+	srv.RequireAuth(oa.Checker())
+	srv.Rsp(http.StatusOK, nil).Rsp(http.StatusOK, nil)
+
+	// --- When ---
+	status0, tok0 := oauth2Token(t, oa.URL(), url.Values{"grant_type": {"client_credentials"}})
+	rsp0 := getWithToken(t, srv.URL(), tok0["access_token"].(string))
+	oa.Expire()
+	rsp1 := getWithToken(t, srv.URL(), tok0["access_token"].(string))
+	status1, tok1 := oauth2Token(t, oa.URL(), url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tok0["refresh_token"].(string)},
+	})
+	rsp2 := getWithToken(t, srv.URL(), tok1["access_token"].(string))
+	status2, tok2 := oauth2Token(t, oa.URL(), url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tok0["refresh_token"].(string)},
+	})
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	assert.Exactly(t, http.StatusOK, status0)
+	assert.Exactly(t, "Bearer", tok0["token_type"])
+	assert.Exactly(t, 60.0, tok0["expires_in"])
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+
+	assert.Exactly(t, http.StatusUnauthorized, rsp1.StatusCode)
+	assert.EqualError(t, srv.AuthError(2), "expired access token")
+
+	assert.Exactly(t, http.StatusOK, status1)
+	assert.NotEqual(t, tok0["access_token"], tok1["access_token"])
+	assert.Exactly(t, http.StatusOK, rsp2.StatusCode)
+
+	assert.Exactly(t, http.StatusBadRequest, status2)
+	assert.Exactly(t, "invalid_grant", tok2["error"])
+
+	tokens := oa.Tokens()
+	require.Len(t, tokens, 2)
+	assert.Exactly(t, "client_credentials", tokens[0].GrantType)
+	assert.Exactly(t, "refresh_token", tokens[1].GrantType)
+}
+
+func Test_HTTPServer_OAuth2_writesWithoutLock(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	oa := srv.OAuth2("id", "secret")
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req := httptest.NewRequest(http.MethodPost, kit.OAuth2TokenPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("id", "secret")
+
+	w := &blockingWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		writing:          make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		oa.ServeHTTP(w, req)
+	}()
+	<-w.writing
+
+	// --- When ---
+	got := oa.Tokens()
+
+	// --- Then ---
+	close(w.release)
+	<-done
+	mck.AssertExpectations(t)
+	require.Len(t, got, 1)
+	assert.Exactly(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), got[0].AccessToken)
+}
+
+func Test_HTTPServer_OAuth2_errors(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	oa0 := kit.NewHTTPServer(mck).OAuth2("id", "other")
+	oa1 := kit.NewHTTPServer(mck).OAuth2("id", "secret")
+
+	// --- When ---
+	status0, rsp0 := oauth2Token(t, oa0.URL(), url.Values{"grant_type": {"client_credentials"}})
+	status1, rsp1 := oauth2Token(t, oa1.URL(), url.Values{"grant_type": {"password"}})
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, http.StatusUnauthorized, status0)
+	assert.Exactly(t, "invalid_client", rsp0["error"])
+	assert.Exactly(t, http.StatusBadRequest, status1)
+	assert.Exactly(t, "unsupported_grant_type", rsp1["error"])
+}
@@ -0,0 +1,372 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// HTTPCall represents request to http.Handler under test.
+//
+// HTTPCall is a builder, every method configures the request and returns
+// it so the calls can be chained. The request is made with Do() which
+// returns HTTPResult with chainable assertions.
+//
+//	kit.Call(t, handler).
+//		POST("/users").
+//		JSON(user).
+//		Header("Authorization", "Bearer token").
+//		Do().
+//		Status(http.StatusCreated).
+//		Header("Location", "/users/1").
+//		JSONPath("name", "Bob")
+type HTTPCall struct {
+	t        T              // Test state manager.
+	h        http.Handler   // Handler under test.
+	loopback bool           // Use real loopback connection.
+	method   string         // Request method.
+	pth      string         // Request path with optional query.
+	query    url.Values     // Additional query parameters.
+	header   http.Header    // Request headers.
+	cookies  []*http.Cookie // Request cookies.
+	body     []byte         // Request body.
+}
+
+// Call returns new instance of HTTPCall making GET / request to handler h.
+func Call(t T, h http.Handler) *HTTPCall {
+	return &HTTPCall{
+		t:      t,
+		h:      h,
+		method: http.MethodGet,
+		pth:    "/",
+		query:  make(url.Values),
+		header: make(http.Header),
+	}
+}
+
+// Loopback makes the request over real loopback connection to a test
+// server serving the handler instead of calling it with recorder.
+// Redirects are not followed.
+func (c *HTTPCall) Loopback() *HTTPCall {
+	c.loopback = true
+	return c
+}
+
+// Method sets request method and path. The path must be absolute and may
+// contain query.
+func (c *HTTPCall) Method(method, pth string) *HTTPCall {
+	c.method = method
+	c.pth = pth
+	return c
+}
+
+// GET sets GET request method and path.
+func (c *HTTPCall) GET(pth string) *HTTPCall { return c.Method(http.MethodGet, pth) }
+
+// HEAD sets HEAD request method and path.
+func (c *HTTPCall) HEAD(pth string) *HTTPCall { return c.Method(http.MethodHead, pth) }
+
+// POST sets POST request method and path.
+func (c *HTTPCall) POST(pth string) *HTTPCall { return c.Method(http.MethodPost, pth) }
+
+// PUT sets PUT request method and path.
+func (c *HTTPCall) PUT(pth string) *HTTPCall { return c.Method(http.MethodPut, pth) }
+
+// PATCH sets PATCH request method and path.
+func (c *HTTPCall) PATCH(pth string) *HTTPCall { return c.Method(http.MethodPatch, pth) }
+
+// DELETE sets DELETE request method and path.
+func (c *HTTPCall) DELETE(pth string) *HTTPCall { return c.Method(http.MethodDelete, pth) }
+
+// Header adds request header value to the key.
+func (c *HTTPCall) Header(key, value string) *HTTPCall {
+	c.header.Add(key, value)
+	return c
+}
+
+// Query adds query parameter value to the key.
+func (c *HTTPCall) Query(key, value string) *HTTPCall {
+	c.query.Add(key, value)
+	return c
+}
+
+// Cookie adds request cookie.
+func (c *HTTPCall) Cookie(ck *http.Cookie) *HTTPCall {
+	c.cookies = append(c.cookies, ck)
+	return c
+}
+
+// Body sets request body.
+func (c *HTTPCall) Body(body []byte) *HTTPCall {
+	c.body = body
+	return c
+}
+
+// JSON sets request body to v marshalled with ToJSON() and sets
+// Content-Type header to "application/json". Calls t.Fatal() on error.
+func (c *HTTPCall) JSON(v interface{}) *HTTPCall {
+	c.t.Helper()
+	c.header.Set("Content-Type", "application/json")
+	return c.Body(ToJSON(c.t, v))
+}
+
+// XML sets request body to v marshalled with ToXML() and sets
+// Content-Type header to "application/xml". Calls t.Fatal() on error.
+func (c *HTTPCall) XML(v interface{}) *HTTPCall {
+	c.t.Helper()
+	c.header.Set("Content-Type", "application/xml")
+	return c.Body(ToXML(c.t, v))
+}
+
+// Form sets request body to URL encoded form values and sets Content-Type
+// header to "application/x-www-form-urlencoded".
+func (c *HTTPCall) Form(values url.Values) *HTTPCall {
+	c.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Body([]byte(values.Encode()))
+}
+
+// Do makes the request and returns the result. Calls t.Fatal() on error.
+func (c *HTTPCall) Do() *HTTPResult {
+	c.t.Helper()
+	target := c.pth
+	if len(c.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + c.query.Encode()
+	}
+	if _, err := url.ParseRequestURI(target); err != nil {
+		c.t.Fatalf("invalid request target %q: %s", target, err)
+		return nil
+	}
+
+	var rsp *http.Response
+	if c.loopback {
+		srv := httptest.NewServer(c.h)
+		defer srv.Close()
+		req, err := http.NewRequest(c.method, srv.URL+target, bytes.NewReader(c.body))
+		if err != nil {
+			c.t.Fatal(err)
+			return nil
+		}
+		c.prepare(req)
+		cli := srv.Client()
+		cli.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		if rsp, err = cli.Do(req); err != nil {
+			c.t.Fatal(err)
+			return nil
+		}
+	} else {
+		req := httptest.NewRequest(c.method, target, bytes.NewReader(c.body))
+		c.prepare(req)
+		rec := httptest.NewRecorder()
+		c.h.ServeHTTP(rec, req)
+		rsp = rec.Result()
+	}
+	defer rsp.Body.Close()
+
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+		return nil
+	}
+	return &HTTPResult{t: c.t, rsp: rsp, body: body}
+}
+
+// prepare sets request headers and cookies.
+func (c *HTTPCall) prepare(req *http.Request) {
+	for key, values := range c.header {
+		req.Header[key] = append([]string{}, values...)
+	}
+	for _, ck := range c.cookies {
+		req.AddCookie(ck)
+	}
+}
+
+// HTTPResult represents result of the HTTPCall.
+//
+// The assertion methods report failures with t.Errorf() and return the
+// result so the assertions can be chained.
+type HTTPResult struct {
+	t    T              // Test state manager.
+	rsp  *http.Response // Received response.
+	body []byte         // Received response body.
+}
+
+// Response returns received response. The body was already read, use
+// Body() to get it.
+func (res *HTTPResult) Response() *http.Response { return res.rsp }
+
+// Body returns response body.
+func (res *HTTPResult) Body() []byte { return res.body }
+
+// BodyString returns response body as a string.
+func (res *HTTPResult) BodyString() string { return string(res.body) }
+
+// DecodeJSON unmarshalls response body to v with FromJSON(). Calls
+// t.Fatal() on error.
+func (res *HTTPResult) DecodeJSON(v interface{}) *HTTPResult {
+	res.t.Helper()
+	FromJSON(res.t, res.body, v)
+	return res
+}
+
+// DecodeXML unmarshalls response body to v with FromXML(). Calls
+// t.Fatal() on error.
+func (res *HTTPResult) DecodeXML(v interface{}) *HTTPResult {
+	res.t.Helper()
+	FromXML(res.t, res.body, v)
+	return res
+}
+
+// Status asserts response status code.
+func (res *HTTPResult) Status(status int) *HTTPResult {
+	res.t.Helper()
+	if res.rsp.StatusCode != status {
+		res.t.Errorf("expected status %d got %d", status, res.rsp.StatusCode)
+	}
+	return res
+}
+
+// Header asserts response header key has value.
+func (res *HTTPResult) Header(key, value string) *HTTPResult {
+	res.t.Helper()
+	if got := res.rsp.Header.Get(key); got != value {
+		res.t.Errorf("expected header %s value %q got %q", key, value, got)
+	}
+	return res
+}
+
+// BodyEqual asserts response body is equal to exp.
+func (res *HTTPResult) BodyEqual(exp []byte) *HTTPResult {
+	res.t.Helper()
+	if !bytes.Equal(exp, res.body) {
+		res.t.Errorf(
+			"response body does not match:\n%s",
+			unifiedDiff(string(exp), string(res.body)),
+		)
+	}
+	return res
+}
+
+// BodyContains asserts response body contains s.
+func (res *HTTPResult) BodyContains(s string) *HTTPResult {
+	res.t.Helper()
+	if !bytes.Contains(res.body, []byte(s)) {
+		res.t.Errorf("expected body %q to contain %q", abbrev(res.body), s)
+	}
+	return res
+}
+
+// JSON asserts response body is JSON equal to JSON representation of v
+// ignoring object field order and formatting. When v is a byte slice or
+// a string it is used as JSON document, unlike in JSONPath where strings
+// are marshalled.
+func (res *HTTPResult) JSON(v interface{}) *HTTPResult {
+	res.t.Helper()
+	var data []byte
+	switch vv := v.(type) {
+	case []byte:
+		data = vv
+	case string:
+		data = []byte(vv)
+	default:
+		data = ToJSON(res.t, v)
+	}
+	exp, ok := res.jsonValue(data)
+	if !ok {
+		return res
+	}
+	var got interface{}
+	if err := json.Unmarshal(res.body, &got); err != nil {
+		res.t.Errorf("invalid JSON response body %q: %s", abbrev(res.body), err)
+		return res
+	}
+	if !reflect.DeepEqual(exp, got) {
+		res.t.Errorf(
+			"response JSON body does not match:\n%s",
+			unifiedDiff(indentJSON(exp), indentJSON(got)),
+		)
+	}
+	return res
+}
+
+// JSONPath asserts value at path in JSON response body is equal to JSON
+// representation of v. The v is always marshalled, so a string is compared
+// with JSON string value, unlike in JSON where it is used as JSON document.
+// The path is a dot separated list of object keys and array indexes, for
+// example "items.0.name". Empty path denotes the whole document.
+func (res *HTTPResult) JSONPath(pth string, v interface{}) *HTTPResult {
+	res.t.Helper()
+	exp, ok := res.jsonValue(ToJSON(res.t, v))
+	if !ok {
+		return res
+	}
+	var doc interface{}
+	if err := json.Unmarshal(res.body, &doc); err != nil {
+		res.t.Errorf("invalid JSON response body %q: %s", abbrev(res.body), err)
+		return res
+	}
+	got, ok := jsonPath(doc, pth)
+	if !ok {
+		res.t.Errorf("JSON path %s not found in response body", pth)
+		return res
+	}
+	if !reflect.DeepEqual(exp, got) {
+		res.t.Errorf(
+			"expected JSON path %s value %s got %s",
+			pth,
+			normJSON(exp),
+			normJSON(got),
+		)
+	}
+	return res
+}
+
+// jsonValue returns JSON data unmarshalled to interface{}. Calls
+// t.Error() and returns false on error.
+func (res *HTTPResult) jsonValue(data []byte) (interface{}, bool) {
+	res.t.Helper()
+	var exp interface{}
+	if err := json.Unmarshal(data, &exp); err != nil {
+		res.t.Error(err)
+		return nil, false
+	}
+	return exp, true
+}
+
+// jsonPath returns value at dot separated path in unmarshalled JSON doc.
+// Returns false if the path does not exist.
+func jsonPath(doc interface{}, pth string) (interface{}, bool) {
+	if pth == "" {
+		return doc, true
+	}
+	for _, key := range strings.Split(pth, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			doc = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
@@ -0,0 +1,205 @@
+package testkit_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	kit "github.com/rzajac/testkit"
+)
+
+// echoHandler responds with JSON describing the request.
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	var cookie string
+	if c, err := r.Cookie("session"); err == nil {
+		cookie = c.Value
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Method", r.Method)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"method": r.Method,
+		"uri":    r.URL.RequestURI(),
+		"ct":     r.Header.Get("Content-Type"),
+		"auth":   r.Header.Get("Authorization"),
+		"cookie": cookie,
+		"body":   string(body),
+		"items":  []interface{}{map[string]interface{}{"id": 1}},
+	})
+})
+
+func Test_Call(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		loopback bool
+	}{
+		{"recorder", false},
+		{"loopback", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			mck := &kit.TMock{}
+			mck.On("Helper")
+
+			call := kit.Call(mck, echoHandler).
+				POST("/users?a=1").
+				Query("b", "2").
+				JSON(map[string]string{"name": "Bob"}).
+				Header("Authorization", "Bearer token").
+				Cookie(&http.Cookie{Name: "session", Value: "abc"})
+			if tc.loopback {
+				call.Loopback()
+			}
+
+			// --- When ---
+			res := call.Do()
+
+			// --- Then ---
+			res.Status(http.StatusCreated).
+				Header("X-Method", http.MethodPost).
+				Header("Content-Type", "application/json").
+				BodyContains(`"auth":"Bearer token"`).
+				JSONPath("uri", "/users?a=1&b=2").
+				JSONPath("ct", "application/json").
+				JSONPath("cookie", "abc").
+				JSONPath("body", `{"name":"Bob"}`).
+				JSONPath("items.0.id", 1).
+				JSONPath("items", []map[string]int{{"id": 1}})
+
+			var got map[string]interface{}
+			res.DecodeJSON(&got)
+
+			mck.AssertExpectations(t)
+			assert.Exactly(t, "POST", got["method"])
+			assert.Exactly(t, http.StatusCreated, res.Response().StatusCode)
+		})
+	}
+}
+
+func Test_Call_JSON(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"b": [1, 2], "a": "x"}`))
+	})
+
+	// --- When ---
+	res := kit.Call(mck, h).GET("/").Do()
+
+	// --- Then ---
+	res.JSON(`{"a":"x","b":[1,2]}`).
+		JSON(map[string]interface{}{"a": "x", "b": []int{1, 2}}).
+		BodyEqual([]byte(`{"b": [1, 2], "a": "x"}`)).
+		JSONPath("", map[string]interface{}{"a": "x", "b": []int{1, 2}})
+
+	mck.AssertExpectations(t)
+	assert.Exactly(t, `{"b": [1, 2], "a": "x"}`, res.BodyString())
+}
+
+func Test_Call_failures(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On("Errorf", "expected status %d got %d", http.StatusOK, http.StatusCreated)
+	mck.On("Errorf", "expected header %s value %q got %q", "X-Method", "GET", "POST")
+	mck.On("Errorf", "expected body %q to contain %q", mock.Anything, "missing")
+	mck.On("Errorf", "JSON path %s not found in response body", "items.1.id")
+	mck.On("Errorf", "expected JSON path %s value %s got %s", "method", `"GET"`, `"POST"`)
+	mck.On("Errorf", "response body does not match:\n%s", mock.Anything)
+	mck.On("Errorf", "response JSON body does not match:\n%s", mock.Anything)
+
+	res := kit.Call(mck, echoHandler).POST("/").Do()
+
+	// --- When ---
+	res.Status(http.StatusOK).
+		Header("X-Method", http.MethodGet).
+		BodyContains("missing").
+		JSONPath("items.1.id", 1).
+		JSONPath("method", http.MethodGet).
+		BodyEqual([]byte("other")).
+		JSON(`{}`)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+}
+
+func Test_Call_invalidJSON(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On("Errorf", "invalid JSON response body %q: %s", "text", mock.Anything).Twice()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("text"))
+	})
+	res := kit.Call(mck, h).Do()
+
+	// --- When ---
+	res.JSON(`{}`).JSONPath("a", 1)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+}
+
+func Test_Call_FormAndXML(t *testing.T) {
+	type Doc struct {
+		XMLName xml.Name `xml:"doc"`
+		Name    string   `xml:"name"`
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") == "application/xml" {
+			body, _ := ioutil.ReadAll(r.Body)
+			_, _ = w.Write(body)
+			return
+		}
+		_ = r.ParseForm()
+		_, _ = w.Write([]byte("<doc><name>" + r.PostForm.Get("name") + "</name></doc>"))
+	})
+
+	// --- When ---
+	var got0, got1 Doc
+	kit.Call(t, h).PUT("/").Form(url.Values{"name": {"Bob"}}).Do().DecodeXML(&got0)
+	kit.Call(t, h).PATCH("/").XML(Doc{Name: "Tom"}).Do().DecodeXML(&got1)
+
+	// --- Then ---
+	assert.Exactly(t, "Bob", got0.Name)
+	assert.Exactly(t, "Tom", got1.Name)
+}
+
+func Test_Call_Loopback_noRedirects(t *testing.T) {
+	// --- Given ---
+	h := http.RedirectHandler("/other", http.StatusFound)
+
+	// --- When ---
+	res := kit.Call(t, h).Loopback().DELETE("/").Do()
+
+	// --- Then ---
+	res.Status(http.StatusFound).Header("Location", "/other")
+}
+
+func Test_Call_invalidTarget(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On("Fatalf", "invalid request target %q: %s", "users", mock.Anything)
+
+	// --- When ---
+	got := kit.Call(mck, echoHandler).GET("users").Do()
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Nil(t, got)
+}
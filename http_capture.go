@@ -0,0 +1,73 @@
+package testkit
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// served represents response written by HTTPServer.
+type served struct {
+	status int           // HTTP status code, zero if nothing was written.
+	header http.Header   // Response headers.
+	body   []byte        // Response body.
+	took   time.Duration // Time it took to serve the request.
+}
+
+// captureWriter is http.ResponseWriter capturing written response.
+type captureWriter struct {
+	http.ResponseWriter
+	status int         // Written status code.
+	header http.Header // Headers at the time status was written.
+	body   []byte      // Written body.
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+		cw.header = cw.ResponseWriter.Header().Clone()
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.body = append(cw.body, p[:n]...)
+	return n, err
+}
+
+// Flush implements http.Flusher.
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (cw *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("connection cannot be hijacked")
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// served returns captured response.
+func (cw *captureWriter) served() served {
+	hdr := cw.header
+	if hdr == nil {
+		hdr = make(http.Header)
+	}
+	return served{status: cw.status, header: hdr, body: cw.body}
+}
@@ -0,0 +1,328 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// CassetteEnv is the name of environment variable selecting cassette mode.
+// Set it to CassetteRecord to record interactions with the upstream, any
+// other value (or not set) replays interactions from the cassette file.
+// Use SetEnv to set it in a test.
+const CassetteEnv = "TESTKIT_CASSETTE"
+
+// Cassette modes.
+const (
+	CassetteRecord = "record" // Record interactions with the upstream.
+	CassetteReplay = "replay" // Replay interactions from the cassette.
+)
+
+// Interaction represents request and response pair stored in a cassette.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest represents request stored in a cassette.
+type CassetteRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"` // Request URI with the query.
+	Header  http.Header `json:"header,omitempty"`
+	Body    string      `json:"body,omitempty"`
+	BodyB64 []byte      `json:"body_base64,omitempty"` // Non UTF-8 body.
+}
+
+// CassetteResponse represents response stored in a cassette.
+type CassetteResponse struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header,omitempty"`
+	Body    string      `json:"body,omitempty"`
+	BodyB64 []byte      `json:"body_base64,omitempty"` // Non UTF-8 body.
+}
+
+// cassette is a handler recording interactions with the upstream or
+// replaying them from the cassette file.
+type cassette struct {
+	pth      string            // Path to the cassette file.
+	upstream *url.URL          // Upstream URL used in record mode.
+	record   bool              // Record mode.
+	redact   func(http.Header) // Header redaction hook, may be nil.
+	cli      *http.Client      // Client used to call the upstream.
+	mx       sync.Mutex        // Guards fields below.
+	ins      []Interaction     // Recorded or loaded interactions.
+	used     []bool            // Replayed interactions.
+	t        T                 // Test state manager.
+}
+
+// WithCassette configures HTTPServer to record interactions with the
+// upstream to the cassette file at pth or replay them from it depending on
+// the value of CassetteEnv environment variable.
+//
+// In record mode the server acts as a reverse proxy to the upstream and
+// saves the interactions to the file when the test is done. Upstream
+// redirects are not followed, they are recorded and returned to the client
+// as they are. In replay mode the requests are answered with responses of
+// the first not replayed interaction with the same method, request URI and
+// body, the upstream is not used. Interactions not replayed by the time
+// the test finishes are reported with t.Errorf(). Routes and responses
+// added to the server take precedence over the cassette. Calls t.Fatal()
+// if the cassette cannot be loaded.
+func WithCassette(pth, upstream string) HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.t.Helper()
+		cas := &cassette{
+			pth:    pth,
+			record: os.Getenv(CassetteEnv) == CassetteRecord,
+			cli: &http.Client{
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			},
+			t: tst.t,
+		}
+		if cas.record {
+			u, err := url.Parse(upstream)
+			if err != nil {
+				tst.t.Fatal(err)
+				return
+			}
+			cas.upstream = u
+			tst.closers = append(tst.closers, cas.save)
+		} else {
+			cas.load()
+			tst.closers = append(tst.closers, cas.verify)
+		}
+		tst.cassette = cas
+		tst.fallback = cas
+	}
+}
+
+// WithRedact sets hook called with copies of request and response headers
+// before they are saved to a cassette or dumped with HAR() and Curl().
+// Use it to remove secrets.
+func WithRedact(fn func(hdr http.Header)) HTTPServerOption {
+	return func(tst *HTTPServer) { tst.redact = fn }
+}
+
+// RedactHeaders returns redaction hook replacing values of headers
+// with names with "REDACTED".
+func RedactHeaders(names ...string) func(hdr http.Header) {
+	return func(hdr http.Header) {
+		for _, name := range names {
+			if _, ok := hdr[http.CanonicalHeaderKey(name)]; ok {
+				hdr.Set(name, "REDACTED")
+			}
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (cas *cassette) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if cas.record {
+		cas.proxy(w, req)
+		return
+	}
+	cas.replay(w, req)
+}
+
+// proxy forwards request to the upstream and records the interaction.
+// Calls t.Error() on error.
+func (cas *cassette) proxy(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		cas.t.Error(err)
+		return
+	}
+
+	u := *cas.upstream
+	u.Path = singleJoin(u.Path, req.URL.Path)
+	u.RawQuery = req.URL.RawQuery
+	out, err := http.NewRequestWithContext(
+		req.Context(),
+		req.Method,
+		u.String(),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		cas.t.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	out.Header = req.Header.Clone()
+
+	rsp, err := cas.cli.Do(out)
+	if err != nil {
+		cas.t.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer rsp.Body.Close()
+	rspBody, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		cas.t.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	for key, values := range rsp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(rsp.StatusCode)
+	_, _ = w.Write(rspBody)
+
+	in := Interaction{
+		Request: CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.RequestURI(),
+			Header: req.Header.Clone(),
+		},
+		Response: CassetteResponse{
+			Status: rsp.StatusCode,
+			Header: rsp.Header.Clone(),
+		},
+	}
+	in.Request.Body, in.Request.BodyB64 = encodeBody(body)
+	in.Response.Body, in.Response.BodyB64 = encodeBody(rspBody)
+	if cas.redact != nil {
+		cas.redact(in.Request.Header)
+		cas.redact(in.Response.Header)
+	}
+
+	cas.mx.Lock()
+	cas.ins = append(cas.ins, in)
+	cas.mx.Unlock()
+}
+
+// replay answers request with the response from the first not replayed
+// interaction matching the request. Calls t.Errorf() and responds with
+// status 500 if there is no matching interaction.
+func (cas *cassette) replay(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		cas.t.Error(err)
+		return
+	}
+
+	cas.mx.Lock()
+	var in *Interaction
+	for i := range cas.ins {
+		if cas.used[i] || !cas.ins[i].matches(req, body) {
+			continue
+		}
+		cas.used[i] = true
+		in = &cas.ins[i]
+		break
+	}
+	cas.mx.Unlock()
+
+	if in == nil {
+		cas.t.Errorf(
+			"no cassette %s interaction matches request %s %s",
+			cas.pth,
+			req.Method,
+			req.URL.RequestURI(),
+		)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for key, values := range in.Response.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(in.Response.Status)
+	_, _ = w.Write(decodeBody(in.Response.Body, in.Response.BodyB64))
+}
+
+// load loads interactions from the cassette file. Calls t.Fatal() on error.
+func (cas *cassette) load() {
+	cas.t.Helper()
+	data := ReadFile(cas.t, cas.pth)
+	FromJSON(cas.t, data, &cas.ins)
+	cas.used = make([]bool, len(cas.ins))
+}
+
+// verify reports interactions which were not replayed with t.Errorf().
+func (cas *cassette) verify() {
+	cas.mx.Lock()
+	defer cas.mx.Unlock()
+	for i, in := range cas.ins {
+		if cas.used[i] {
+			continue
+		}
+		cas.t.Errorf(
+			"cassette %s interaction %d %s %s was not replayed",
+			cas.pth,
+			i,
+			in.Request.Method,
+			in.Request.URL,
+		)
+	}
+}
+
+// save writes recorded interactions to the cassette file creating
+// directories as needed. Calls t.Error() on error.
+func (cas *cassette) save() {
+	cas.mx.Lock()
+	defer cas.mx.Unlock()
+	data, err := json.MarshalIndent(cas.ins, "", "  ")
+	if err != nil {
+		cas.t.Error(err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(cas.pth), 0755); err != nil {
+		cas.t.Error(err)
+		return
+	}
+	if err := ioutil.WriteFile(cas.pth, data, 0644); err != nil {
+		cas.t.Error(err)
+	}
+}
+
+// matches returns true if the interaction request has the same method,
+// request URI and body as req.
+func (in Interaction) matches(req *http.Request, body []byte) bool {
+	if in.Request.Method != req.Method {
+		return false
+	}
+	if in.Request.URL != req.URL.RequestURI() {
+		return false
+	}
+	return bytes.Equal(decodeBody(in.Request.Body, in.Request.BodyB64), body)
+}
+
+// encodeBody returns body as a string if it's valid UTF-8 otherwise
+// returns it as byte slice.
+func encodeBody(body []byte) (string, []byte) {
+	if utf8.Valid(body) {
+		return string(body), nil
+	}
+	return "", body
+}
+
+// decodeBody returns body encoded with encodeBody.
+func decodeBody(str string, bin []byte) []byte {
+	if bin != nil {
+		return bin
+	}
+	return []byte(str)
+}
+
+// singleJoin joins two URL paths with a single slash.
+func singleJoin(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case a[len(a)-1] == '/' && len(b) > 0 && b[0] == '/':
+		return a + b[1:]
+	case a[len(a)-1] != '/' && (len(b) == 0 || b[0] != '/'):
+		return a + "/" + b
+	}
+	return a + b
+}
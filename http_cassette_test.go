@@ -0,0 +1,169 @@
+package testkit_test
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_WithCassette(t *testing.T) {
+	// --- Given ---
+	pth := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+
+	// --- When ---
+	t.Run("record", func(t *testing.T) {
+		kit.SetEnv(t, kit.CassetteEnv, kit.CassetteRecord)
+
+		upstream := kit.NewHTTPServer(t)
+		upstream.Response().Header("X-Secret", "s3cr3t").Body([]byte("users"))
+		upstream.Response().Status(http.StatusCreated).Body([]byte("created"))
+
+		srv := kit.NewHTTPServer(
+			t,
+			kit.WithCassette(pth, upstream.URL()),
+			kit.WithRedact(kit.RedactHeaders("Authorization", "X-Secret")),
+		)
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL()+"/users?page=1", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		rsp0, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		rsp1, err := http.Post(srv.URL()+"/users", "", bytes.NewReader([]byte("{}")))
+		require.NoError(t, err)
+
+		assert.Exactly(t, "users", string(getResponseBody(t, rsp0)))
+		assert.Exactly(t, "s3cr3t", rsp0.Header.Get("X-Secret"))
+		assert.Exactly(t, http.StatusCreated, rsp1.StatusCode)
+		assert.Exactly(t, "Bearer token", upstream.Headers(0).Get("Authorization"))
+		assert.Exactly(t, "/users?page=1", upstream.Request(0).URL.RequestURI())
+		assert.Exactly(t, "{}", upstream.BodyString(1))
+	})
+
+	// --- Then ---
+	var ins []kit.Interaction
+	kit.FromJSON(t, kit.ReadFile(t, pth), &ins)
+	require.Len(t, ins, 2)
+	assert.Exactly(t, "REDACTED", ins[0].Request.Header.Get("Authorization"))
+	assert.Exactly(t, "REDACTED", ins[0].Response.Header.Get("X-Secret"))
+	assert.Exactly(t, "/users?page=1", ins[0].Request.URL)
+
+	t.Run("replay", func(t *testing.T) {
+		kit.SetEnv(t, kit.CassetteEnv, kit.CassetteReplay)
+
+		srv := kit.NewHTTPServer(t, kit.WithCassette(pth, ""))
+
+		rsp1, err := http.Post(srv.URL()+"/users", "", bytes.NewReader([]byte("{}")))
+		require.NoError(t, err)
+		rsp0, err := http.Get(srv.URL() + "/users?page=1")
+		require.NoError(t, err)
+
+		assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+		assert.Exactly(t, "users", string(getResponseBody(t, rsp0)))
+		assert.Exactly(t, "REDACTED", rsp0.Header.Get("X-Secret"))
+		assert.Exactly(t, http.StatusCreated, rsp1.StatusCode)
+		assert.Exactly(t, "created", string(getResponseBody(t, rsp1)))
+		assert.Exactly(t, 2, srv.ReqCount())
+	})
+}
+
+func Test_HTTPServer_WithCassette_noMatchingInteraction(t *testing.T) {
+	// --- Given ---
+	pth := kit.TempFileBuf(t, t.TempDir(), []byte("[]"))
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"no cassette %s interaction matches request %s %s",
+		pth,
+		http.MethodGet,
+		"/users",
+	)
+
+	srv := kit.NewHTTPServer(mck, kit.WithCassette(pth, ""))
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL() + "/users")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusInternalServerError, rsp.StatusCode)
+}
+
+func Test_HTTPServer_WithCassette_redirect(t *testing.T) {
+	// --- Given ---
+	pth := filepath.Join(t.TempDir(), "cassette.json")
+	kit.SetEnv(t, kit.CassetteEnv, kit.CassetteRecord)
+
+	upstream := kit.NewHTTPServer(t)
+	upstream.Response().Status(http.StatusFound).Header("Location", "/new")
+
+	cli := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// --- When ---
+	t.Run("record", func(t *testing.T) {
+		srv := kit.NewHTTPServer(t, kit.WithCassette(pth, upstream.URL()))
+		rsp, err := cli.Get(srv.URL() + "/old")
+		require.NoError(t, err)
+		assert.Exactly(t, http.StatusFound, rsp.StatusCode)
+	})
+
+	// --- Then ---
+	var ins []kit.Interaction
+	kit.FromJSON(t, kit.ReadFile(t, pth), &ins)
+	require.Len(t, ins, 1)
+	assert.Exactly(t, http.StatusFound, ins[0].Response.Status)
+	assert.Exactly(t, "/new", ins[0].Response.Header.Get("Location"))
+	assert.Exactly(t, 1, upstream.ReqCount())
+}
+
+func Test_HTTPServer_WithCassette_notReplayed(t *testing.T) {
+	// --- Given ---
+	data := []byte(`[
+		{"request": {"method": "GET", "url": "/a"}, "response": {"status": 200}},
+		{"request": {"method": "POST", "url": "/b"}, "response": {"status": 201}}
+	]`)
+	pth := kit.TempFileBuf(t, t.TempDir(), data)
+
+	var cleanups []func()
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything).Run(func(args mock.Arguments) {
+		cleanups = append(cleanups, args.Get(0).(func()))
+	})
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"cassette %s interaction %d %s %s was not replayed",
+		pth,
+		1,
+		http.MethodPost,
+		"/b",
+	)
+
+	srv := kit.NewHTTPServer(mck, kit.WithCassette(pth, ""))
+	rsp, err := http.Get(srv.URL() + "/a")
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+
+	// --- When ---
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+}
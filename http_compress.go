@@ -0,0 +1,167 @@
+package testkit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WithDecompress configures HTTPServer to decompress received request
+// bodies with gzip or deflate Content-Encoding. The decompressed bodies
+// are recorded and used by matchers and expectations, handlers (cassette,
+// fixtures, ...) and auth checkers receive the original body, so request
+// signatures are verified against the bytes sent by the client. Request
+// headers are recorded as received. Calls t.Error() when the body cannot
+// be decompressed and records it as received.
+func WithDecompress() HTTPServerOption {
+	return func(tst *HTTPServer) { tst.decompress = true }
+}
+
+// WithCompress configures HTTPServer to compress responses with gzip or
+// deflate when the request Accept-Encoding header allows it. Responses
+// with Content-Encoding header already set, responses to HEAD requests and
+// responses without body (204, 304) are not compressed.
+func WithCompress() HTTPServerOption {
+	return func(tst *HTTPServer) { tst.compress = true }
+}
+
+// decompress returns body decoded according to Content-Encoding header
+// value enc. Returns body unchanged for other encodings.
+func decompress(enc string, body []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(enc)) {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// Some clients send raw deflate stream instead of zlib one.
+		if r, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			r, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return body, nil
+	}
+	if err != nil {
+		return body, fmt.Errorf("cannot decompress %s request body: %w", enc, err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return body, fmt.Errorf("cannot decompress %s request body: %w", enc, err)
+	}
+	return data, nil
+}
+
+// acceptEncoding returns the preferred supported encoding ("gzip" or
+// "deflate") allowed by Accept-Encoding header value hdr. Returns empty
+// string if none is allowed.
+func acceptEncoding(hdr string) string {
+	allowed := make(map[string]bool)
+	for _, v := range strings.Split(hdr, ",") {
+		parts := strings.Split(v, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		ok := true
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				ok = err == nil && q > 0
+			}
+		}
+		if name != "" {
+			allowed[name] = ok
+		}
+	}
+	for _, enc := range []string{"gzip", "deflate"} {
+		if ok, set := allowed[enc]; ok || (!set && allowed["*"]) {
+			return enc
+		}
+	}
+	return ""
+}
+
+// compressWriter is http.ResponseWriter compressing response body.
+type compressWriter struct {
+	http.ResponseWriter
+	accept string         // Encoding allowed by the client.
+	head   bool           // Response to HEAD request.
+	enc    string         // Encoding used, empty if not compressed.
+	zw     io.WriteCloser // Compressing writer.
+	wrote  bool           // Status was written.
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wrote || status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wrote = true
+	hdr := cw.Header()
+	if !cw.head &&
+		status != http.StatusNoContent &&
+		status != http.StatusNotModified &&
+		hdr.Get("Content-Encoding") == "" {
+		hdr.Del("Content-Length")
+		hdr.Set("Content-Encoding", cw.accept)
+		hdr.Add("Vary", "Accept-Encoding")
+		cw.enc = cw.accept
+		if cw.enc == "gzip" {
+			cw.zw = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.zw = zlib.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wrote {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("connection cannot be hijacked")
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close flushes compressed data.
+func (cw *compressWriter) close() {
+	if cw.zw != nil {
+		_ = cw.zw.Close()
+	}
+}
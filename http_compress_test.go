@@ -0,0 +1,208 @@
+package testkit_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+// gzipBytes returns gzip compressed data.
+func gzipBytes(t *testing.T, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// zlibBytes returns zlib compressed data.
+func zlibBytes(t *testing.T, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func Test_HTTPServer_WithDecompress(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		enc  string
+		body func(t *testing.T, data []byte) []byte
+	}{
+		{"gzip", "gzip", gzipBytes},
+		{"deflate", "deflate", zlibBytes},
+		{"identity", "", func(_ *testing.T, data []byte) []byte { return data }},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			mck := &kit.TMock{}
+			mck.On("Cleanup", mock.Anything)
+			mck.On("Helper")
+
+			srv := kit.NewHTTPServer(mck, kit.WithDecompress())
+			srv.Route(kit.MatchBodyEqual([]byte(`{"a":1}`))).Rsp(http.StatusOK, nil)
+
+			body := tc.body(t, []byte(`{"a":1}`))
+			req, err := http.NewRequest(http.MethodPost, srv.URL(), bytes.NewReader(body))
+			require.NoError(t, err)
+			if tc.enc != "" {
+				req.Header.Set("Content-Encoding", tc.enc)
+			}
+
+			// --- When ---
+			rsp, err := http.DefaultClient.Do(req)
+
+			// --- Then ---
+			mck.AssertExpectations(t)
+			require.NoError(t, err)
+			assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+			assert.Exactly(t, `{"a":1}`, srv.BodyString(0))
+			assert.Exactly(t, tc.enc, srv.Headers(0).Get("Content-Encoding"))
+		})
+	}
+}
+
+func Test_HTTPServer_WithDecompress_error(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Error", mock.MatchedBy(func(err error) bool {
+		return err.Error() == "cannot decompress gzip request body: unexpected EOF"
+	}))
+
+	srv := kit.NewHTTPServer(mck, kit.WithDecompress()).Rsp(http.StatusOK, nil)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL(), bytes.NewReader([]byte{0x1f}))
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+
+	// --- When ---
+	_, err = http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, []byte{0x1f}, srv.Body(0))
+}
+
+func Test_HTTPServer_WithDecompress_HMACAuth(t *testing.T) {
+	// --- Given ---
+	key := []byte("secret")
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithDecompress()).
+		RequireAuth(kit.HMACAuth("X-Sig", "", key)).
+		Rsp(http.StatusOK, nil)
+
+	body := gzipBytes(t, []byte(`{"a":1}`))
+	req, err := http.NewRequest(http.MethodPost, srv.URL(), bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-Sig", kit.HMACSHA256(key, body))
+
+	// --- When ---
+	rsp, err := http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.NoError(t, srv.AuthError(0))
+	assert.Exactly(t, `{"a":1}`, srv.BodyString(0))
+}
+
+func Test_HTTPServer_WithCompress(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		accept string
+		exp    string
+	}{
+		{"gzip", "gzip, deflate", "gzip"},
+		{"deflate", "deflate", "deflate"},
+		{"gzip not acceptable", "gzip;q=0, deflate;q=0.5", "deflate"},
+		{"any", "*", "gzip"},
+		{"none", "br", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			mck := &kit.TMock{}
+			mck.On("Cleanup", mock.Anything)
+			mck.On("Helper")
+
+			srv := kit.NewHTTPServer(mck, kit.WithCompress())
+			srv.Response().ContentType("text/plain").Body([]byte("response body"))
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL(), nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept-Encoding", tc.accept)
+
+			// --- When ---
+			rsp, err := http.DefaultClient.Do(req)
+
+			// --- Then ---
+			mck.AssertExpectations(t)
+			require.NoError(t, err)
+			assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+			assert.Exactly(t, tc.exp, rsp.Header.Get("Content-Encoding"))
+
+			body := getResponseBody(t, rsp)
+			switch tc.exp {
+			case "gzip":
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				require.NoError(t, err)
+				body, err = ioutil.ReadAll(zr)
+				require.NoError(t, err)
+			case "deflate":
+				zr, err := zlib.NewReader(bytes.NewReader(body))
+				require.NoError(t, err)
+				body, err = ioutil.ReadAll(zr)
+				require.NoError(t, err)
+			}
+			assert.Exactly(t, "response body", string(body))
+		})
+	}
+}
+
+func Test_HTTPServer_WithCompress_noBody(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithCompress()).Rsp(http.StatusNoContent, nil)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL(), nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// --- When ---
+	rsp, err := http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusNoContent, rsp.StatusCode)
+	assert.Exactly(t, "", rsp.Header.Get("Content-Encoding"))
+}
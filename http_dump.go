@@ -0,0 +1,318 @@
+package testkit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// WithDumpHAR configures HTTPServer to write recorded requests and served
+// responses as HAR file to directory dir when the test fails. When dir is
+// empty string new directory is created in os.TempDir(), it's not removed
+// when the test finishes so the file can be inspected. The path to the
+// written file is logged with t.Log().
+//
+// The test failure is detected only when t implements Failed() method.
+func WithDumpHAR(dir string) HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.closers = append(tst.closers, func() {
+			if !failed(tst.t) {
+				return
+			}
+			dir := dir
+			if dir == "" {
+				var err error
+				if dir, err = os.MkdirTemp("", "testkit-har-"); err != nil {
+					tst.t.Error(err)
+					return
+				}
+			}
+			pth := filepath.Join(dir, fmt.Sprintf("testkit-%d.har", time.Now().UnixNano()))
+			if err := ioutil.WriteFile(pth, tst.HAR(), 0644); err != nil {
+				tst.t.Error(err)
+				return
+			}
+			tst.t.Log("HTTPServer traffic written to " + pth)
+		})
+	}
+}
+
+// WithDumpCurl configures HTTPServer to log recorded requests as curl
+// commands along with the status codes of served responses when the
+// test fails.
+//
+// The test failure is detected only when t implements Failed() method.
+func WithDumpCurl() HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.closers = append(tst.closers, func() {
+			if !failed(tst.t) {
+				return
+			}
+			tst.mx.Lock()
+			recs := append([]record{}, tst.requests...)
+			tst.mx.Unlock()
+			for i, rec := range recs {
+				tst.t.Logf(
+					"HTTPServer request %d (response status %d):\n%s",
+					i,
+					rec.served().status,
+					rec.curl(tst.redact),
+				)
+			}
+		})
+	}
+}
+
+// Curl returns the nth received request as curl command. Headers are
+// redacted with hook set by WithRedact. Calls t.Fatal() if n is greater
+// than number or received requests.
+func (tst *HTTPServer) Curl(n int) string {
+	tst.t.Helper()
+	if rec, ok := tst.record(n); ok {
+		return rec.curl(tst.redact)
+	}
+	return ""
+}
+
+// HAR returns recorded requests and served responses in HTTP Archive
+// format. Headers are redacted with hook set by WithRedact.
+func (tst *HTTPServer) HAR() []byte {
+	tst.mx.Lock()
+	recs := make([]record, len(tst.requests))
+	copy(recs, tst.requests)
+	outs := make([]served, len(recs))
+	for i, rec := range recs {
+		outs[i] = *rec.out
+	}
+	tst.mx.Unlock()
+
+	doc := harDoc{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "testkit", Version: "1"},
+		Entries: make([]harEntry, 0, len(recs)),
+	}}
+	for i, rec := range recs {
+		doc.Log.Entries = append(doc.Log.Entries, rec.har(outs[i], tst.redact))
+	}
+
+	data, _ := json.MarshalIndent(doc, "", "  ")
+	return data
+}
+
+// served returns copy of served response.
+func (rec record) served() served {
+	if rec.out == nil {
+		return served{}
+	}
+	return *rec.out
+}
+
+// curl returns the request as curl command. The headers are redacted with
+// redact hook if it's not nil.
+func (rec record) curl(redact func(http.Header)) string {
+	hdr := rec.req.Header.Clone()
+	if redact != nil {
+		redact(hdr)
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "curl -X %s %s", rec.req.Method, shellQuote(rec.req.URL.String()))
+	for _, key := range sortedKeys(hdr) {
+		for _, v := range hdr[key] {
+			fmt.Fprintf(&buf, " \\\n  -H %s", shellQuote(key+": "+v))
+		}
+	}
+	if len(rec.body) > 0 {
+		fmt.Fprintf(&buf, " \\\n  --data-binary %s", shellQuote(string(rec.body)))
+	}
+	return buf.String()
+}
+
+// har returns HAR entry for the request and served response out. The
+// headers are redacted with redact hook if it's not nil.
+func (rec record) har(out served, redact func(http.Header)) harEntry {
+	reqHdr := rec.req.Header.Clone()
+	rspHdr := out.header.Clone()
+	if rspHdr == nil {
+		rspHdr = make(http.Header)
+	}
+	if redact != nil {
+		redact(reqHdr)
+		redact(rspHdr)
+	}
+
+	e := harEntry{
+		StartedDateTime: rec.at.Format(time.RFC3339Nano),
+		Time:            float64(out.took) / float64(time.Millisecond),
+		Request: harRequest{
+			Method:      rec.req.Method,
+			URL:         rec.req.URL.String(),
+			HTTPVersion: rec.req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(reqHdr),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(rec.body),
+		},
+		Response: harResponse{
+			Status:      out.status,
+			StatusText:  http.StatusText(out.status),
+			HTTPVersion: rec.req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(rspHdr),
+			Content: harContent{
+				Size:     len(out.body),
+				MimeType: rspHdr.Get("Content-Type"),
+			},
+			HeadersSize: -1,
+			BodySize:    len(out.body),
+		},
+		Cache:   struct{}{},
+		Timings: harTimings{Wait: float64(out.took) / float64(time.Millisecond)},
+	}
+
+	q := rec.req.URL.Query()
+	for _, key := range sortedKeys(q) {
+		for _, v := range q[key] {
+			e.Request.QueryString = append(
+				e.Request.QueryString,
+				harNameValue{Name: key, Value: v},
+			)
+		}
+	}
+
+	if len(rec.body) > 0 {
+		e.Request.PostData = &harPostData{
+			MimeType: reqHdr.Get("Content-Type"),
+			Text:     string(rec.body),
+		}
+	}
+	if utf8.Valid(out.body) {
+		e.Response.Content.Text = string(out.body)
+	} else {
+		e.Response.Content.Text = base64.StdEncoding.EncodeToString(out.body)
+		e.Response.Content.Encoding = "base64"
+	}
+	return e
+}
+
+// harDoc represents HTTP Archive document.
+type harDoc struct {
+	Log harLog `json:"log"`
+}
+
+// harLog represents HTTP Archive log.
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+// harCreator represents HTTP Archive creator.
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// harEntry represents HTTP Archive entry.
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+// harRequest represents HTTP Archive request.
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// harResponse represents HTTP Archive response.
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// harNameValue represents HTTP Archive name and value pair.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harPostData represents HTTP Archive request body.
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// harContent represents HTTP Archive response body.
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// harTimings represents HTTP Archive entry timings.
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harHeaders returns headers as HTTP Archive name and value pairs.
+func harHeaders(hdr http.Header) []harNameValue {
+	nvs := make([]harNameValue, 0, len(hdr))
+	for _, key := range sortedKeys(hdr) {
+		for _, v := range hdr[key] {
+			nvs = append(nvs, harNameValue{Name: key, Value: v})
+		}
+	}
+	return nvs
+}
+
+// sortedKeys returns sorted keys of the map.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shellQuote returns s quoted for POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// failed returns true if t implements Failed() method and the test failed.
+func failed(t T) bool {
+	f, ok := t.(interface{ Failed() bool })
+	return ok && f.Failed()
+}
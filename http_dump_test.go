@@ -0,0 +1,213 @@
+package testkit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+// failedTMock is TMock reporting failed test.
+type failedTMock struct {
+	*kit.TMock
+}
+
+// Failed implements Failed() method of testing.TB.
+func (failedTMock) Failed() bool { return true }
+
+func Test_HTTPServer_Curl(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithRedact(kit.RedactHeaders("Authorization")))
+	srv.Rsp(http.StatusOK, nil)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		srv.URL()+"/users?a=1",
+		bytes.NewReader([]byte("it's body")),
+	)
+	require.NoError(t, err)
+	req.Header = http.Header{}
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Test", "abc")
+
+	// --- When ---
+	_, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	exp := "curl -X POST '" + srv.URL() + "/users?a=1' \\\n" +
+		"  -H 'Accept-Encoding: gzip' \\\n" +
+		"  -H 'Authorization: REDACTED' \\\n" +
+		"  -H 'Content-Length: 9' \\\n" +
+		"  -H 'User-Agent: Go-http-client/1.1' \\\n" +
+		"  -H 'X-Test: abc' \\\n" +
+		"  --data-binary 'it'\\''s body'"
+	assert.Exactly(t, exp, srv.Curl(0))
+}
+
+func Test_HTTPServer_HAR(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().Status(http.StatusCreated).JSON(map[string]int{"id": 1})
+
+	// --- When ---
+	_, err := http.Post(srv.URL()+"/users?a=1", "text/plain", strings.NewReader("req"))
+	require.NoError(t, err)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Entries []struct {
+				Request struct {
+					Method      string `json:"method"`
+					URL         string `json:"url"`
+					QueryString []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"queryString"`
+					PostData struct {
+						MimeType string `json:"mimeType"`
+						Text     string `json:"text"`
+					} `json:"postData"`
+				} `json:"request"`
+				Response struct {
+					Status  int `json:"status"`
+					Content struct {
+						MimeType string `json:"mimeType"`
+						Text     string `json:"text"`
+					} `json:"content"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	kit.FromJSON(t, srv.HAR(), &har)
+
+	assert.Exactly(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 1)
+	e := har.Log.Entries[0]
+	assert.Exactly(t, http.MethodPost, e.Request.Method)
+	assert.Exactly(t, srv.URL()+"/users?a=1", e.Request.URL)
+	require.Len(t, e.Request.QueryString, 1)
+	assert.Exactly(t, "a", e.Request.QueryString[0].Name)
+	assert.Exactly(t, "text/plain", e.Request.PostData.MimeType)
+	assert.Exactly(t, "req", e.Request.PostData.Text)
+	assert.Exactly(t, http.StatusCreated, e.Response.Status)
+	assert.Exactly(t, "application/json", e.Response.Content.MimeType)
+	assert.Exactly(t, `{"id":1}`, e.Response.Content.Text)
+}
+
+func Test_HTTPServer_WithDumpHAR(t *testing.T) {
+	// --- Given ---
+	dir := t.TempDir()
+
+	mck := &kit.TMock{}
+	var cleanup func()
+	mck.On("Cleanup", mock.MatchedBy(func(fn func()) bool {
+		cleanup = fn
+		return true
+	}))
+	mck.On("Helper")
+	mck.On("Log", mock.AnythingOfType("string"))
+
+	srv := kit.NewHTTPServer(failedTMock{mck}, kit.WithDumpHAR(dir))
+	srv.Rsp(http.StatusOK, []byte("rsp"))
+
+	// --- When ---
+	_, err := http.Get(srv.URL())
+	require.NoError(t, err)
+	cleanup()
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	files, err := filepath.Glob(filepath.Join(dir, "*.har"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := ioutil.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"text": "rsp"`)
+}
+
+func Test_HTTPServer_WithDumpHAR_defaultDir(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	var cleanup func()
+	mck.On("Cleanup", mock.MatchedBy(func(fn func()) bool {
+		cleanup = fn
+		return true
+	}))
+	mck.On("Helper")
+	var msg string
+	mck.On("Log", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		msg = args.String(0)
+	})
+
+	srv := kit.NewHTTPServer(failedTMock{mck}, kit.WithDumpHAR(""))
+	srv.Rsp(http.StatusOK, []byte("rsp"))
+
+	// --- When ---
+	_, err := http.Get(srv.URL())
+	require.NoError(t, err)
+	cleanup()
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	pth := strings.TrimPrefix(msg, "HTTPServer traffic written to ")
+	require.NotEqual(t, msg, pth)
+	defer os.RemoveAll(filepath.Dir(pth))
+	assert.Exactly(t, os.TempDir(), filepath.Dir(filepath.Dir(pth)))
+	data, err := ioutil.ReadFile(pth)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"text": "rsp"`)
+}
+
+func Test_HTTPServer_WithDumpCurl(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	var cleanup func()
+	mck.On("Cleanup", mock.MatchedBy(func(fn func()) bool {
+		cleanup = fn
+		return true
+	}))
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(failedTMock{mck}, kit.WithDumpCurl())
+	srv.Rsp(http.StatusAccepted, nil)
+	mck.On(
+		"Logf",
+		"HTTPServer request %d (response status %d):\n%s",
+		0,
+		http.StatusAccepted,
+		"curl -X GET '"+srv.URL()+"/' \\\n"+
+			"  -H 'Accept-Encoding: gzip' \\\n"+
+			"  -H 'User-Agent: Go-http-client/1.1'",
+	)
+
+	// --- When ---
+	_, err := http.Get(srv.URL() + "/")
+	require.NoError(t, err)
+	cleanup()
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+}
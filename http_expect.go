@@ -0,0 +1,59 @@
+package testkit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// verify verifies recorded requests meet the expectations of responses
+// they were answered with. Reports every request not meeting them with
+// t.Errorf().
+func (tst *HTTPServer) verify() {
+	tst.t.Helper()
+	tst.mx.Lock()
+	recs := append([]record{}, tst.requests...)
+	tst.mx.Unlock()
+
+	for i, rec := range recs {
+		if rec.rsp == nil || len(rec.rsp.expect) == 0 {
+			continue
+		}
+		if report, ok := rec.verify(); !ok {
+			tst.t.Errorf(
+				"request %d %s %s does not match expectations:\n%s",
+				i,
+				rec.req.Method,
+				rec.req.URL.RequestURI(),
+				report,
+			)
+		}
+	}
+}
+
+// verify verifies the request meets the expectations of the response it
+// was answered with. Returns report listing expected and received values
+// and false if any expectation is not met.
+func (rec record) verify() (string, bool) {
+	ok := true
+	var buf strings.Builder
+	for _, m := range rec.rsp.expect {
+		res := "ok  "
+		match := m.Match(rec.req, rec.body)
+		if !match {
+			res = "FAIL"
+			ok = false
+		}
+		fmt.Fprintf(&buf, "  %s expected: %s\n", res, m)
+		if recv := m.received(rec.req, rec.body); recv != "" {
+			fmt.Fprintf(&buf, "       received: %s\n", recv)
+		}
+		if !match && m.diff != nil {
+			for _, line := range strings.SplitAfter(m.diff(rec.body), "\n") {
+				if line != "" {
+					fmt.Fprintf(&buf, "       %s", line)
+				}
+			}
+		}
+	}
+	return strings.TrimRight(buf.String(), "\n"), ok
+}
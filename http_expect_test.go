@@ -0,0 +1,100 @@
+package testkit_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_Response_Expect(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	var cleanup func()
+	mck.On("Cleanup", mock.MatchedBy(func(fn func()) bool {
+		cleanup = fn
+		return true
+	}))
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().Expect(
+		kit.MatchMethod(http.MethodPost),
+		kit.MatchPath("/users"),
+		kit.MatchQuery("dry", "1"),
+		kit.MatchHeader("Content-Type", "application/json"),
+		kit.MatchJSON(map[string]interface{}{"name": "bob", "age": 42}),
+	)
+
+	// --- When ---
+	rsp, err := http.Post(
+		srv.URL()+"/users?dry=1",
+		"application/json",
+		bytes.NewReader([]byte(`{"age":42, "name":"bob"}`)),
+	)
+	cleanup()
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+}
+
+func Test_Response_Expect_report(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	var cleanup func()
+	mck.On("Cleanup", mock.MatchedBy(func(fn func()) bool {
+		cleanup = fn
+		return true
+	}))
+	mck.On("Helper")
+
+	exp := "" +
+		"  FAIL expected: method PUT\n" +
+		"       received: method POST\n" +
+		"  ok   expected: path /users\n" +
+		"       received: path /users\n" +
+		"  FAIL expected: JSON body {\"age\":42,\"name\":\"bob\"}\n" +
+		"       received: JSON body {\"age\":41,\"name\":\"bob\"}\n" +
+		"       --- expected\n" +
+		"       +++ received\n" +
+		"       @@ -1,4 +1,4 @@\n" +
+		"        {\n" +
+		"       -  \"age\": 42,\n" +
+		"       +  \"age\": 41,\n" +
+		"          \"name\": \"bob\"\n" +
+		"        }"
+	mck.On(
+		"Errorf",
+		"request %d %s %s does not match expectations:\n%s",
+		0,
+		http.MethodPost,
+		"/users",
+		exp,
+	)
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().Expect(
+		kit.MatchMethod(http.MethodPut),
+		kit.MatchPath("/users"),
+		kit.MatchJSON(`{"name": "bob", "age": 42}`),
+	)
+
+	// --- When ---
+	_, err := http.Post(
+		srv.URL()+"/users",
+		"application/json",
+		bytes.NewReader([]byte(`{"name":"bob","age":41}`)),
+	)
+	cleanup()
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+}
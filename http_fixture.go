@@ -0,0 +1,165 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// fixtureMetaExt is the extension of fixture sidecar metadata files.
+const fixtureMetaExt = ".meta.json"
+
+// FixtureMeta represents fixture sidecar metadata file. Header values in
+// the file are strings or arrays of strings for multi-value headers.
+//
+//	{"status": 201, "headers": {"Location": "/users/2", "Set-Cookie": ["a=1", "b=2"]}}
+type FixtureMeta struct {
+	Status  int         `json:"status"`  // Response status, 200 if zero.
+	Headers http.Header `json:"headers"` // Response headers.
+}
+
+// UnmarshalJSON implements json.Unmarshaler accepting header values as
+// strings or arrays of strings.
+func (fm *FixtureMeta) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Status  int                        `json:"status"`
+		Headers map[string]json.RawMessage `json:"headers"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	fm.Status = raw.Status
+	fm.Headers = make(http.Header, len(raw.Headers))
+	for key, msg := range raw.Headers {
+		var values []string
+		if err := json.Unmarshal(msg, &values); err != nil {
+			var value string
+			if err := json.Unmarshal(msg, &value); err != nil {
+				return fmt.Errorf("invalid fixture header %s value %s", key, msg)
+			}
+			values = []string{value}
+		}
+		for _, value := range values {
+			fm.Headers.Add(key, value)
+		}
+	}
+	return nil
+}
+
+// fixtures is a handler answering requests with response fixtures from
+// the file system.
+type fixtures struct {
+	fsys fs.FS // Fixtures file system.
+	t    T     // Test state manager.
+}
+
+// Fixtures adds route answering requests with response fixtures from the
+// file system fsys (for example embed.FS) and returns it.
+//
+// The request path and method map to file named after the method in the
+// directory named after the path. For example GET /users/1 is answered
+// with the "users/1/GET.json" file, POST / with "POST.json". The response
+// Content-Type is set based on the file extension. The optional sidecar
+// file with ".meta.json" extension ("users/1/GET.meta.json") may set the
+// response status and headers, see FixtureMeta.
+//
+// The route matches only requests for which the fixture exists. Routes and
+// responses added before the fixtures take precedence. Requests answered
+// with fixtures are not counted as expected requests by the server.
+func (tst *HTTPServer) Fixtures(fsys fs.FS) *Route {
+	fix := &fixtures{fsys: fsys, t: tst.t}
+	return tst.Route(NewMatcher("fixture exists", func(req *http.Request, _ []byte) bool {
+		_, ok := fix.lookup(req)
+		return ok
+	})).Handler(fix)
+}
+
+// FixturesDir adds route answering requests with response fixtures from
+// the directory dir. See Fixtures for details.
+func (tst *HTTPServer) FixturesDir(dir string) *Route {
+	return tst.Fixtures(os.DirFS(dir))
+}
+
+// ServeHTTP implements http.Handler. Calls t.Error() and responds with
+// status 500 if the fixture cannot be read.
+func (fix *fixtures) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name, ok := fix.lookup(req)
+	if !ok {
+		fix.t.Errorf("no fixture for request %s %s", req.Method, req.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	body, err := fs.ReadFile(fix.fsys, name)
+	if err != nil {
+		fix.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	meta, err := fix.meta(name)
+	if err != nil {
+		fix.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	for key, values := range meta.Headers {
+		w.Header()[key] = append([]string{}, values...)
+	}
+	if meta.Status == 0 {
+		meta.Status = http.StatusOK
+	}
+	w.WriteHeader(meta.Status)
+	_, _ = w.Write(body)
+}
+
+// lookup returns name of the fixture file for the request. Returns false
+// if the fixture does not exist.
+func (fix *fixtures) lookup(req *http.Request) (string, bool) {
+	dir := strings.Trim(path.Clean("/"+req.URL.Path), "/")
+	if dir == "" {
+		dir = "."
+	}
+	if !fs.ValidPath(dir) {
+		return "", false
+	}
+	ents, err := fs.ReadDir(fix.fsys, dir)
+	if err != nil {
+		return "", false
+	}
+	for _, ent := range ents {
+		name := ent.Name()
+		if ent.IsDir() ||
+			!strings.HasPrefix(name, req.Method+".") ||
+			strings.HasSuffix(name, fixtureMetaExt) {
+			continue
+		}
+		return path.Join(dir, name), true
+	}
+	return "", false
+}
+
+// meta returns metadata for the fixture file name. Returns zero value
+// FixtureMeta if the sidecar file does not exist.
+func (fix *fixtures) meta(name string) (FixtureMeta, error) {
+	var meta FixtureMeta
+	pth := strings.TrimSuffix(name, path.Ext(name)) + fixtureMetaExt
+	data, err := fs.ReadFile(fix.fsys, pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
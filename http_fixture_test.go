@@ -0,0 +1,194 @@
+package testkit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_Fixtures(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	fsys := fstest.MapFS{
+		"GET.txt":                  {Data: []byte("root")},
+		"users/1/GET.json":         {Data: []byte(`{"id":1}`)},
+		"users/POST.json":          {Data: []byte(`{"id":2}`)},
+		"users/POST.meta.json":     {Data: []byte(`{"status":201,"headers":{"location":"/users/2","Set-Cookie":["a=1","b=2"]}}`)},
+		"users/1/DELETE.meta.json": {Data: []byte(`{"status":204}`)},
+	}
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Fixtures(fsys)
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL() + "/")
+	rsp1, err1 := http.Get(srv.URL() + "/users/1")
+	rsp2, err2 := http.Post(srv.URL()+"/users", "application/json", bytes.NewReader([]byte(`{}`)))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, "text/plain; charset=utf-8", rsp0.Header.Get("Content-Type"))
+	assert.Exactly(t, "root", string(getResponseBody(t, rsp0)))
+
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusOK, rsp1.StatusCode)
+	assert.Exactly(t, "application/json", rsp1.Header.Get("Content-Type"))
+	assert.Exactly(t, `{"id":1}`, string(getResponseBody(t, rsp1)))
+
+	require.NoError(t, err2)
+	assert.Exactly(t, http.StatusCreated, rsp2.StatusCode)
+	assert.Exactly(t, "/users/2", rsp2.Header.Get("Location"))
+	assert.Exactly(t, []string{"a=1", "b=2"}, rsp2.Header.Values("Set-Cookie"))
+	assert.Exactly(t, `{"id":2}`, string(getResponseBody(t, rsp2)))
+
+	assert.Exactly(t, 3, srv.ReqCount())
+}
+
+func Test_HTTPServer_Fixtures_missing(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"no route matches request %s %s, closest route: %s",
+		http.MethodDelete,
+		"/users/1",
+		"fixture exists (no match)",
+	)
+
+	fsys := fstest.MapFS{
+		"users/1/GET.json":         {Data: []byte(`{"id":1}`)},
+		"users/1/DELETE.meta.json": {Data: []byte(`{"status":204}`)},
+	}
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Fixtures(fsys)
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL()+"/users/1", nil)
+	require.NoError(t, err)
+
+	// --- When ---
+	rsp, err := http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusInternalServerError, rsp.StatusCode)
+}
+
+func Test_HTTPServer_Fixtures_invalidMeta(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Error", mock.MatchedBy(func(err error) bool {
+		return err.Error() == "invalid fixture header X-Count value 1"
+	}))
+
+	fsys := fstest.MapFS{
+		"GET.json":      {Data: []byte(`{}`)},
+		"GET.meta.json": {Data: []byte(`{"headers":{"X-Count":1}}`)},
+	}
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Fixtures(fsys)
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusInternalServerError, rsp.StatusCode)
+}
+
+func Test_HTTPServer_FixturesDir(t *testing.T) {
+	// --- Given ---
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "orders"), 0755))
+	pth := filepath.Join(dir, "orders", "GET.json")
+	require.NoError(t, ioutil.WriteFile(pth, []byte(`[]`), 0644))
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.FixturesDir(dir)
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL() + "/orders")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "[]", string(getResponseBody(t, rsp)))
+}
+
+func Test_HTTPServer_Fixtures_routesTakePrecedence(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	fsys := fstest.MapFS{"users/GET.json": {Data: []byte(`["fixture"]`)}}
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Route(kit.MatchPath("/users")).Rsp(http.StatusOK, []byte(`["route"]`))
+	srv.Fixtures(fsys)
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL() + "/users")
+	rsp1, err1 := http.Get(srv.URL() + "/users")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, `["route"]`, string(getResponseBody(t, rsp0)))
+	require.NoError(t, err1)
+	assert.Exactly(t, `["fixture"]`, string(getResponseBody(t, rsp1)))
+}
+
+func Test_Route_Handler(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	rt := srv.Route(kit.MatchPath("/ping"))
+	rt.Rsp(http.StatusAccepted, nil)
+	rt.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL() + "/ping")
+	rsp1, err1 := http.Get(srv.URL() + "/ping")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusAccepted, rsp0.StatusCode)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusTeapot, rsp1.StatusCode)
+	assert.Exactly(t, 2, rt.Calls())
+}
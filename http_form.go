@@ -0,0 +1,122 @@
+package testkit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+)
+
+// FormFile represents file part of multipart/form-data request.
+type FormFile struct {
+	Field       string // Form field name.
+	Filename    string // File name.
+	ContentType string // Part Content-Type.
+	Content     []byte // File content.
+}
+
+// Form returns form values of the nth received request with
+// application/x-www-form-urlencoded or multipart/form-data body. For
+// multipart requests only non-file parts are returned. Calls t.Fatal() if
+// n is greater than number or received requests or the body cannot be
+// parsed.
+func (tst *HTTPServer) Form(n int) url.Values {
+	tst.t.Helper()
+	vs, _, err := tst.form(n)
+	if err != nil {
+		tst.t.Fatal(err)
+		return nil
+	}
+	return vs
+}
+
+// FormValue returns the first value of the form field of the nth received
+// request. See Form for details.
+func (tst *HTTPServer) FormValue(n int, field string) string {
+	tst.t.Helper()
+	return tst.Form(n).Get(field)
+}
+
+// FormFiles returns file parts of the nth received request with
+// multipart/form-data body in order they were sent. Calls t.Fatal() if n
+// is greater than number or received requests or the body cannot be
+// parsed.
+func (tst *HTTPServer) FormFiles(n int) []FormFile {
+	tst.t.Helper()
+	_, files, err := tst.form(n)
+	if err != nil {
+		tst.t.Fatal(err)
+		return nil
+	}
+	return files
+}
+
+// FormFile returns the first file part for the form field of the nth
+// received request. Calls t.Fatal() if the request has no such file or
+// for any of the reasons described in FormFiles.
+func (tst *HTTPServer) FormFile(n int, field string) FormFile {
+	tst.t.Helper()
+	for _, f := range tst.FormFiles(n) {
+		if f.Field == field {
+			return f
+		}
+	}
+	tst.t.Fatalf("request %d has no file for form field %s", n, field)
+	return FormFile{}
+}
+
+// form parses body of the nth received request as a form.
+func (tst *HTTPServer) form(n int) (url.Values, []FormFile, error) {
+	tst.t.Helper()
+	rec, ok := tst.record(n)
+	if !ok {
+		return nil, nil, nil
+	}
+	ct := rec.req.Header.Get("Content-Type")
+	typ, params, _ := mime.ParseMediaType(ct)
+	switch typ {
+	case "application/x-www-form-urlencoded":
+		vs, err := url.ParseQuery(string(rec.body))
+		return vs, nil, err
+	case "multipart/form-data":
+		return parseMultipart(rec.body, params["boundary"])
+	}
+	return nil, nil, fmt.Errorf("request %d is not a form, Content-Type: %q", n, ct)
+}
+
+// parseMultipart parses multipart/form-data body with boundary.
+func parseMultipart(body []byte, boundary string) (url.Values, []FormFile, error) {
+	if boundary == "" {
+		return nil, nil, errors.New("multipart boundary not set")
+	}
+	vs := make(url.Values)
+	var files []FormFile
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return vs, files, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		if part.FileName() == "" {
+			vs.Add(part.FormName(), string(data))
+			continue
+		}
+		files = append(files, FormFile{
+			Field:       part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Content:     data,
+		})
+	}
+}
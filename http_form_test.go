@@ -0,0 +1,128 @@
+package testkit_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_Form_urlencoded(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck).Rsp(http.StatusOK, nil)
+
+	// --- When ---
+	_, err := http.PostForm(srv.URL(), url.Values{"a": {"1", "2"}, "b": {"x"}})
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, url.Values{"a": {"1", "2"}, "b": {"x"}}, srv.Form(0))
+	assert.Exactly(t, "x", srv.FormValue(0, "b"))
+}
+
+func Test_HTTPServer_Form_multipart(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck).Rsp(http.StatusOK, nil)
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	require.NoError(t, mw.WriteField("name", "report"))
+	fw, err := mw.CreateFormFile("doc", "a.txt")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("content a"))
+	require.NoError(t, err)
+	hdr := textproto.MIMEHeader{}
+	hdr.Set("Content-Disposition", `form-data; name="img"; filename="b.png"`)
+	hdr.Set("Content-Type", "image/png")
+	pw, err := mw.CreatePart(hdr)
+	require.NoError(t, err)
+	_, err = pw.Write([]byte{0x89, 0x50})
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	// --- When ---
+	_, err = http.Post(srv.URL(), mw.FormDataContentType(), buf)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, url.Values{"name": {"report"}}, srv.Form(0))
+
+	exp := []kit.FormFile{
+		{
+			Field:       "doc",
+			Filename:    "a.txt",
+			ContentType: "application/octet-stream",
+			Content:     []byte("content a"),
+		},
+		{
+			Field:       "img",
+			Filename:    "b.png",
+			ContentType: "image/png",
+			Content:     []byte{0x89, 0x50},
+		},
+	}
+	assert.Exactly(t, exp, srv.FormFiles(0))
+	assert.Exactly(t, exp[1], srv.FormFile(0, "img"))
+}
+
+func Test_HTTPServer_Form_notForm(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Fatal", mock.MatchedBy(func(err error) bool {
+		return err.Error() == `request 0 is not a form, Content-Type: "application/json"`
+	}))
+
+	srv := kit.NewHTTPServer(mck).Rsp(http.StatusOK, nil)
+	_, err := http.Post(srv.URL(), "application/json", bytes.NewReader([]byte("{}")))
+	require.NoError(t, err)
+
+	// --- When ---
+	vs := srv.Form(0)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Nil(t, vs)
+}
+
+func Test_HTTPServer_FormFile_missing(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Fatalf", "request %d has no file for form field %s", 0, "doc")
+
+	srv := kit.NewHTTPServer(mck).Rsp(http.StatusOK, nil)
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	require.NoError(t, mw.WriteField("name", "report"))
+	require.NoError(t, mw.Close())
+	_, err := http.Post(srv.URL(), mw.FormDataContentType(), buf)
+	require.NoError(t, err)
+
+	// --- When ---
+	f := srv.FormFile(0, "doc")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, kit.FormFile{}, f)
+}
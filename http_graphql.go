@@ -0,0 +1,331 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// GraphQLRequest represents GraphQL request.
+type GraphQLRequest struct {
+	// Operation name. When not set in the request it is the name of the
+	// first operation defined in the query document.
+	OperationName string `json:"operationName"`
+
+	// Query document.
+	Query string `json:"query"`
+
+	// Operation variables.
+	Variables map[string]interface{} `json:"variables"`
+
+	// Protocol extensions, e.g. persisted query hash.
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+// GraphQL represents GraphQL endpoint mounted on HTTPServer.
+//
+// GraphQL clients send all the operations to the same path, the endpoint
+// routes requests by the operation name to responses added with Op(),
+// Data() or Errors() methods. The operations are accepted as JSON POST
+// requests, POST requests with "application/graphql" body and GET requests
+// with "query", "operationName" and "variables" query parameters. Batched
+// requests (JSON array of operations) are not supported, they don't match
+// any operation and are reported as unmatched requests by the server.
+//
+//	gql := srv.GraphQL("/graphql")
+//	gql.Data("GetUser", map[string]interface{}{"user": user})
+//
+//	// Make requests.
+//
+//	assert.Exactly(t, "1", gql.Variables("GetUser", 0)["id"])
+type GraphQL struct {
+	tst  *HTTPServer       // The server.
+	path string            // Endpoint path.
+	mx   sync.Mutex        // Guards fields below.
+	ops  map[string]*Route // Routes by operation name.
+}
+
+// GraphQL returns GraphQL endpoint at path pth. The endpoint is created
+// with the first call for the path, next calls return the same instance.
+func (tst *HTTPServer) GraphQL(pth string) *GraphQL {
+	pth = "/" + strings.Trim(pth, "/")
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
+	if gql, ok := tst.graphql[pth]; ok {
+		return gql
+	}
+	gql := &GraphQL{
+		tst:  tst,
+		path: pth,
+		ops:  make(map[string]*Route),
+	}
+	if tst.graphql == nil {
+		tst.graphql = make(map[string]*GraphQL)
+	}
+	tst.graphql[pth] = gql
+	return gql
+}
+
+// Op returns route matching requests for operation name at the endpoint
+// path. The route is registered with the first call for the operation. Use
+// it to add responses, handlers and expectations for the operation.
+// Requests for operations without responses are not answered with
+// responses added with HTTPServer.Rsp(), they are reported with t.Errorf()
+// as unmatched requests and answered with status 500.
+func (gql *GraphQL) Op(name string) *Route {
+	gql.mx.Lock()
+	defer gql.mx.Unlock()
+	if rt, ok := gql.ops[name]; ok {
+		return rt
+	}
+	rt := gql.tst.Route(MatchPath(gql.path), MatchGraphQL(name))
+	gql.ops[name] = rt
+	return rt
+}
+
+// Data adds response for operation name with "data" field set to v.
+// Calls t.Fatal() if v cannot be marshalled to JSON.
+func (gql *GraphQL) Data(name string, v interface{}) *GraphQL {
+	gql.tst.t.Helper()
+	gql.Op(name).Response().JSON(map[string]interface{}{"data": v})
+	return gql
+}
+
+// Errors adds response for operation name with "errors" field listing
+// messages msgs and null "data" field.
+func (gql *GraphQL) Errors(name string, msgs ...string) *GraphQL {
+	gql.tst.t.Helper()
+	errs := make([]map[string]interface{}, 0, len(msgs))
+	for _, msg := range msgs {
+		errs = append(errs, map[string]interface{}{"message": msg})
+	}
+	gql.Op(name).Response().JSON(map[string]interface{}{
+		"data":   nil,
+		"errors": errs,
+	})
+	return gql
+}
+
+// Calls returns requests received by the endpoint for operation name in
+// the order they were received.
+func (gql *GraphQL) Calls(name string) []GraphQLRequest {
+	gql.tst.mx.Lock()
+	defer gql.tst.mx.Unlock()
+	var calls []GraphQLRequest
+	for _, rec := range gql.tst.requests {
+		if rec.req.URL.Path != gql.path {
+			continue
+		}
+		if gr, ok := parseGraphQL(rec.req, rec.body); ok && gr.OperationName == name {
+			calls = append(calls, gr)
+		}
+	}
+	return calls
+}
+
+// Call returns the nth request received by the endpoint for operation
+// name. Calls t.Fatal() if n is greater than number of received requests
+// for the operation.
+func (gql *GraphQL) Call(name string, n int) GraphQLRequest {
+	gql.tst.t.Helper()
+	calls := gql.Calls(name)
+	if n < 0 || n >= len(calls) {
+		gql.tst.t.Fatalf("no graphql operation %s call with index %d recorded", name, n)
+		return GraphQLRequest{}
+	}
+	return calls[n]
+}
+
+// Variables returns variables of the nth request received by the endpoint
+// for operation name. Calls t.Fatal() if n is greater than number of
+// received requests for the operation.
+func (gql *GraphQL) Variables(name string, n int) map[string]interface{} {
+	gql.tst.t.Helper()
+	return gql.Call(name, n).Variables
+}
+
+// MatchGraphQL returns matcher matching GraphQL requests for operation
+// name. Use empty name to match anonymous operations.
+func MatchGraphQL(name string) Matcher {
+	m := NewMatcher(
+		fmt.Sprintf("GraphQL operation %q", name),
+		func(req *http.Request, body []byte) bool {
+			gr, ok := parseGraphQL(req, body)
+			return ok && gr.OperationName == name
+		},
+	)
+	m.recv = func(req *http.Request, body []byte) string {
+		gr, ok := parseGraphQL(req, body)
+		if !ok {
+			return "not a GraphQL request"
+		}
+		return fmt.Sprintf("GraphQL operation %q", gr.OperationName)
+	}
+	return m
+}
+
+// MatchGraphQLVariables returns matcher matching GraphQL requests with
+// variables equal to JSON representation of v ignoring object field order.
+// When v is a byte slice or a string it is used as JSON document. Panics
+// if v cannot be represented as JSON.
+func MatchGraphQLVariables(v interface{}) Matcher {
+	var exp interface{}
+	if err := json.Unmarshal(jsonBytes(v), &exp); err != nil {
+		panic(err)
+	}
+	expStr := normJSON(exp)
+
+	vars := func(req *http.Request, body []byte) (interface{}, bool) {
+		gr, ok := parseGraphQL(req, body)
+		if !ok {
+			return nil, false
+		}
+		var got interface{}
+		if gr.Variables != nil {
+			_ = json.Unmarshal(jsonBytes(gr.Variables), &got)
+		}
+		return got, true
+	}
+
+	m := NewMatcher(
+		fmt.Sprintf("GraphQL variables %s", abbrev([]byte(expStr))),
+		func(req *http.Request, body []byte) bool {
+			got, ok := vars(req, body)
+			return ok && reflect.DeepEqual(exp, got)
+		},
+	)
+	m.recv = func(req *http.Request, body []byte) string {
+		got, ok := vars(req, body)
+		if !ok {
+			return "not a GraphQL request"
+		}
+		return fmt.Sprintf("GraphQL variables %s", abbrev([]byte(normJSON(got))))
+	}
+	return m
+}
+
+// parseGraphQL parses GraphQL request. Returns false if the request is not
+// a GraphQL request.
+func parseGraphQL(req *http.Request, body []byte) (GraphQLRequest, bool) {
+	var gr GraphQLRequest
+	switch {
+	case req.Method == http.MethodGet:
+		q := req.URL.Query()
+		gr.Query = q.Get("query")
+		gr.OperationName = q.Get("operationName")
+		if vs := q.Get("variables"); vs != "" {
+			if err := json.Unmarshal([]byte(vs), &gr.Variables); err != nil {
+				return GraphQLRequest{}, false
+			}
+		}
+		if es := q.Get("extensions"); es != "" {
+			if err := json.Unmarshal([]byte(es), &gr.Extensions); err != nil {
+				return GraphQLRequest{}, false
+			}
+		}
+
+	case strings.HasPrefix(req.Header.Get("Content-Type"), "application/graphql"):
+		gr.Query = string(body)
+
+	default:
+		if err := json.Unmarshal(body, &gr); err != nil {
+			return GraphQLRequest{}, false
+		}
+	}
+
+	if gr.Query == "" && gr.Extensions == nil {
+		return GraphQLRequest{}, false
+	}
+	if gr.OperationName == "" {
+		gr.OperationName = graphqlOperation(gr.Query)
+	}
+	return gr, true
+}
+
+// graphqlOperation returns name of the first operation defined in the
+// GraphQL query document. Returns empty string for anonymous operations.
+func graphqlOperation(doc string) string {
+	var depth int
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' {
+				i++
+			}
+
+		case c == '"':
+			i = graphqlSkipString(doc, i)
+
+		case c == '{' || c == '(':
+			depth++
+			i++
+
+		case c == '}' || c == ')':
+			depth--
+			i++
+
+		case graphqlNameStart(c):
+			word := graphqlName(doc, i)
+			i += len(word)
+			if depth > 0 {
+				continue
+			}
+			switch word {
+			case "query", "mutation", "subscription":
+				for i < len(doc) && strings.ContainsRune(" \t\r\n,", rune(doc[i])) {
+					i++
+				}
+				if i < len(doc) && graphqlNameStart(doc[i]) {
+					return graphqlName(doc, i)
+				}
+				return ""
+			}
+
+		default:
+			i++
+		}
+	}
+	return ""
+}
+
+// graphqlSkipString returns index after the string or block string
+// starting at index i.
+func graphqlSkipString(doc string, i int) int {
+	if strings.HasPrefix(doc[i:], `"""`) {
+		if end := strings.Index(doc[i+3:], `"""`); end >= 0 {
+			return i + 3 + end + 3
+		}
+		return len(doc)
+	}
+	for i++; i < len(doc); i++ {
+		switch doc[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(doc)
+}
+
+// graphqlNameStart returns true if c can start GraphQL name.
+func graphqlNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// graphqlName returns GraphQL name starting at index i.
+func graphqlName(doc string, i int) string {
+	j := i
+	for j < len(doc) {
+		c := doc[j]
+		if !graphqlNameStart(c) && (c < '0' || c > '9') {
+			break
+		}
+		j++
+	}
+	return doc[i:j]
+}
@@ -0,0 +1,291 @@
+package testkit_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_GraphQL(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	gql := srv.GraphQL("/graphql")
+	gql.Data("GetUser", map[string]interface{}{"user": map[string]interface{}{"id": "1"}})
+	gql.Data("GetUser", map[string]interface{}{"user": nil})
+	gql.Errors("DeleteUser", "forbidden")
+
+	body0 := `{"query":"query GetUser($id: ID!) { user(id: $id) { id } }","variables":{"id":"1"}}`
+	body1 := `{"operationName":"DeleteUser","query":"mutation DeleteUser { deleteUser }"}`
+	body2 := `{"query":"# comment\nquery GetUser { user(id: \"2\") { id } }","variables":{"id":"2"}}`
+
+	// --- When ---
+	rsp0, err0 := http.Post(srv.URL()+"/graphql", "application/json", bytes.NewReader([]byte(body0)))
+	rsp1, err1 := http.Post(srv.URL()+"/graphql", "application/json", bytes.NewReader([]byte(body1)))
+	rsp2, err2 := http.Post(srv.URL()+"/graphql", "application/json", bytes.NewReader([]byte(body2)))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	require.NoError(t, err0)
+	assert.Exactly(t, "application/json", rsp0.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"data":{"user":{"id":"1"}}}`, string(getResponseBody(t, rsp0)))
+
+	require.NoError(t, err1)
+	exp := `{"data":null,"errors":[{"message":"forbidden"}]}`
+	assert.JSONEq(t, exp, string(getResponseBody(t, rsp1)))
+
+	require.NoError(t, err2)
+	assert.JSONEq(t, `{"data":{"user":null}}`, string(getResponseBody(t, rsp2)))
+
+	calls := gql.Calls("GetUser")
+	require.Len(t, calls, 2)
+	assert.Exactly(t, "GetUser", calls[0].OperationName)
+	assert.Exactly(t, map[string]interface{}{"id": "1"}, gql.Variables("GetUser", 0))
+	assert.Exactly(t, map[string]interface{}{"id": "2"}, gql.Variables("GetUser", 1))
+	assert.Exactly(t, "mutation DeleteUser { deleteUser }", gql.Call("DeleteUser", 0).Query)
+	assert.Nil(t, gql.Calls("Other"))
+	assert.Exactly(t, 2, gql.Op("GetUser").Calls())
+}
+
+func Test_HTTPServer_GraphQL_samePath(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.GraphQL("/graphql").Data("GetUser", "first")
+	srv.GraphQL("graphql/").Data("GetUser", "second")
+
+	body := `{"query":"query GetUser { user }"}`
+
+	// --- When ---
+	rsp0, err0 := http.Post(srv.URL()+"/graphql", "application/json", bytes.NewReader([]byte(body)))
+	rsp1, err1 := http.Post(srv.URL()+"/graphql", "application/json", bytes.NewReader([]byte(body)))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.JSONEq(t, `{"data":"first"}`, string(getResponseBody(t, rsp0)))
+	require.NoError(t, err1)
+	assert.JSONEq(t, `{"data":"second"}`, string(getResponseBody(t, rsp1)))
+	assert.Same(t, srv.GraphQL("/graphql"), srv.GraphQL("graphql"))
+	assert.Same(t, srv.GraphQL("/graphql").Op("GetUser"), srv.GraphQL("graphql").Op("GetUser"))
+}
+
+func Test_HTTPServer_GraphQL_requestFormats(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		method string
+		ct     string
+		body   string
+		query  url.Values
+		op     string
+	}{
+		{
+			"get",
+			http.MethodGet,
+			"",
+			"",
+			url.Values{
+				"query":     {"query Q1 { a }"},
+				"variables": {`{"a":1}`},
+			},
+			"Q1",
+		},
+		{
+			"get with operation name",
+			http.MethodGet,
+			"",
+			"",
+			url.Values{
+				"query":         {"query Q1 { a } query Q2 { b }"},
+				"operationName": {"Q2"},
+			},
+			"Q2",
+		},
+		{
+			"application/graphql",
+			http.MethodPost,
+			"application/graphql",
+			"fragment F on User { id } subscription S3 { ...F }",
+			nil,
+			"S3",
+		},
+		{
+			"anonymous",
+			http.MethodPost,
+			"application/json",
+			`{"query":"{ user(name: \"query X\") { id } }"}`,
+			nil,
+			"",
+		},
+		{
+			"anonymous with keyword",
+			http.MethodPost,
+			"application/json",
+			`{"query":"query { a }"}`,
+			nil,
+			"",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			mck := &kit.TMock{}
+			mck.On("Cleanup", mock.Anything)
+			mck.On("Helper")
+
+			srv := kit.NewHTTPServer(mck)
+			gql := srv.GraphQL("graphql")
+			gql.Data(tc.op, true)
+
+			u := srv.URL() + "/graphql?" + tc.query.Encode()
+			req, err := http.NewRequest(tc.method, u, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tc.ct)
+
+			// --- When ---
+			rsp, err := http.DefaultClient.Do(req)
+
+			// --- Then ---
+			mck.AssertExpectations(t)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"data":true}`, string(getResponseBody(t, rsp)))
+			assert.Len(t, gql.Calls(tc.op), 1)
+		})
+	}
+}
+
+func Test_HTTPServer_GraphQL_unmatched(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"no graphql response for operation %q request %s %s",
+		"GetPost",
+		http.MethodPost,
+		"/graphql",
+	)
+
+	srv := kit.NewHTTPServer(mck)
+	gql := srv.GraphQL("/graphql").Data("GetUser", nil)
+
+	// --- When ---
+	rsp, err := http.Post(
+		srv.URL()+"/graphql",
+		"application/json",
+		bytes.NewReader([]byte(`{"query":"query GetPost { post { id } }"}`)),
+	)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusInternalServerError, rsp.StatusCode)
+	assert.Len(t, gql.Calls("GetPost"), 1)
+	assert.Len(t, gql.Calls("GetUser"), 0)
+}
+
+func Test_HTTPServer_GraphQL_noResponse(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"no graphql response for operation %q request %s %s",
+		"Other",
+		http.MethodPost,
+		"/graphql",
+	)
+	mck.On(
+		"Errorf",
+		"no graphql response for operation %q request %s %s",
+		"Other",
+		http.MethodPost,
+		"/empty",
+	)
+
+	srv := kit.NewHTTPServer(mck)
+	srv.GraphQL("/graphql").Data("GetUser", nil)
+	srv.GraphQL("/empty")
+	srv.Rsp(http.StatusOK, []byte("generic"))
+
+	body := []byte(`{"query":"query Other { other }"}`)
+
+	// --- When ---
+	rsp0, err0 := http.Post(srv.URL()+"/graphql", "application/json", bytes.NewReader(body))
+	rsp1, err1 := http.Post(srv.URL()+"/empty", "application/json", bytes.NewReader(body))
+	rsp2, err2 := http.Get(srv.URL() + "/other")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusInternalServerError, rsp0.StatusCode)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusInternalServerError, rsp1.StatusCode)
+	require.NoError(t, err2)
+	assert.Exactly(t, "generic", string(getResponseBody(t, rsp2)))
+}
+
+func Test_HTTPServer_GraphQL_Call_notRecorded(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Fatalf", "no graphql operation %s call with index %d recorded", "GetUser", 0)
+
+	srv := kit.NewHTTPServer(mck)
+	gql := srv.GraphQL("/graphql")
+
+	// --- When ---
+	got := gql.Variables("GetUser", 0)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Nil(t, got)
+}
+
+func Test_MatchGraphQLVariables(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		body string
+		exp  bool
+	}{
+		{"equal", `{"query":"query Q { a }","variables":{"b":[1],"a":"x"}}`, true},
+		{"different", `{"query":"query Q { a }","variables":{"a":"y"}}`, false},
+		{"no variables", `{"query":"query Q { a }"}`, false},
+		{"not graphql", `{"a":"x"}`, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			m := kit.MatchGraphQLVariables(`{"a":"x","b":[1]}`)
+			req, err := http.NewRequest(http.MethodPost, "/graphql", nil)
+			require.NoError(t, err)
+
+			// --- When ---
+			got := m.Match(req, []byte(tc.body))
+
+			// --- Then ---
+			assert.Exactly(t, tc.exp, got)
+		})
+	}
+}
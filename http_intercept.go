@@ -0,0 +1,104 @@
+package testkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// interceptSchemeHeader is the header passing the original URL scheme of
+// intercepted requests to the test server. The server removes it before
+// recording the request.
+const interceptSchemeHeader = "X-Testkit-Intercepted-Scheme"
+
+// interceptTransport is http.RoundTripper sending requests for chosen
+// hosts to the target and all other requests to the next RoundTripper.
+type interceptTransport struct {
+	hosts  []string                                        // Intercepted hosts.
+	target func(req *http.Request) (*http.Response, error) // Intercepted requests target.
+	next   http.RoundTripper                               // Transport for other requests.
+}
+
+// RoundTrip implements http.RoundTripper.
+func (it *interceptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, host := range it.hosts {
+		if host == req.URL.Host || host == req.URL.Hostname() {
+			return it.target(req)
+		}
+	}
+	return it.next.RoundTrip(req)
+}
+
+// Transport returns http.RoundTripper sending requests for hosts (with or
+// without the port) to the test server and all other requests to
+// http.DefaultTransport. The requests are sent over a real connection to
+// the server URL (not in-process like with HandlerTransport), so all the
+// response features like stalls or connection resets work. The requests are
+// recorded with the original URL scheme, URL host and Host header.
+func (tst *HTTPServer) Transport(hosts ...string) http.RoundTripper {
+	return tst.transport(http.DefaultTransport, hosts)
+}
+
+// Intercept installs Transport for hosts on the client cli or on
+// http.DefaultTransport when cli is nil. Requests for other hosts are sent
+// with the transport previously used. The previous transport is restored
+// when the test finishes.
+func (tst *HTTPServer) Intercept(cli *http.Client, hosts ...string) {
+	if cli == nil {
+		prev := http.DefaultTransport
+		http.DefaultTransport = tst.transport(prev, hosts)
+		tst.t.Cleanup(func() { http.DefaultTransport = prev })
+		return
+	}
+	prev := cli.Transport
+	next := prev
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	cli.Transport = tst.transport(next, hosts)
+	tst.t.Cleanup(func() { cli.Transport = prev })
+}
+
+// transport returns intercepting transport sending requests for hosts to
+// the test server and other requests to next.
+func (tst *HTTPServer) transport(next http.RoundTripper, hosts []string) http.RoundTripper {
+	tr := tst.Client().Transport
+	return &interceptTransport{
+		hosts: hosts,
+		next:  next,
+		target: func(req *http.Request) (*http.Response, error) {
+			out := req.Clone(req.Context())
+			if out.Host == "" {
+				out.Host = req.URL.Host
+			}
+			out.Header.Set(interceptSchemeHeader, req.URL.Scheme)
+			out.URL.Scheme = tst.scheme
+			out.URL.Host = tst.host
+			return tr.RoundTrip(out)
+		},
+	}
+}
+
+// HandlerTransport returns http.RoundTripper answering requests for hosts
+// (with or without the port) in-process with handler h and sending all
+// other requests to http.DefaultTransport. Use NewHTTPSpy with Transport
+// when the requests need to be recorded.
+func HandlerTransport(h http.Handler, hosts ...string) http.RoundTripper {
+	return &interceptTransport{
+		hosts: hosts,
+		next:  http.DefaultTransport,
+		target: func(req *http.Request) (*http.Response, error) {
+			in := req.Clone(req.Context())
+			if in.Body == nil {
+				in.Body = http.NoBody
+			}
+			if in.Host == "" {
+				in.Host = req.URL.Host
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, in)
+			rsp := rec.Result()
+			rsp.Request = req
+			return rsp, nil
+		},
+	}
+}
@@ -0,0 +1,144 @@
+package testkit_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_Transport(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Route(kit.MatchPath("/users")).Rsp(http.StatusOK, []byte("users"))
+	other := kit.NewHTTPServer(mck).Rsp(http.StatusAccepted, nil)
+
+	cli := &http.Client{Transport: srv.Transport("api.example.com")}
+
+	// --- When ---
+	rsp0, err0 := cli.Get("https://api.example.com/users?a=1")
+	rsp1, err1 := cli.Get(other.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, "users", string(getResponseBody(t, rsp0)))
+	assert.Exactly(t, 1, srv.ReqCount())
+	req := srv.Request(0)
+	assert.Exactly(t, "api.example.com", req.Host)
+	assert.Exactly(t, "api.example.com", req.URL.Host)
+	assert.Exactly(t, "https", req.URL.Scheme)
+	assert.Exactly(t, "", req.Header.Get("X-Testkit-Intercepted-Scheme"))
+	assert.Exactly(t, "1", srv.Values(0).Get("a"))
+
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusAccepted, rsp1.StatusCode)
+	assert.Exactly(t, 1, other.ReqCount())
+}
+
+func Test_HTTPServer_Transport_TLS(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithTLS()).Rsp(http.StatusOK, nil)
+	cli := &http.Client{Transport: srv.Transport("api.example.com:8443")}
+
+	// --- When ---
+	rsp, err := cli.Get("https://api.example.com:8443/")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "api.example.com:8443", srv.Request(0).Host)
+	assert.Exactly(t, "https://api.example.com:8443/", srv.Request(0).URL.String())
+}
+
+func Test_HTTPServer_Intercept(t *testing.T) {
+	// --- Given ---
+	var cleanups []func()
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything).Run(func(args mock.Arguments) {
+		cleanups = append(cleanups, args.Get(0).(func()))
+	})
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck).Rsp(http.StatusOK, nil)
+	cli := &http.Client{}
+
+	// --- When ---
+	srv.Intercept(cli, "api.example.com")
+	rsp, err := cli.Get("http://api.example.com/")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "http://api.example.com/", srv.Request(0).URL.String())
+	assert.NotNil(t, cli.Transport)
+
+	// Restores client transport.
+	cleanups[len(cleanups)-1]()
+	assert.Nil(t, cli.Transport)
+
+	for i := len(cleanups) - 2; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+func Test_HTTPServer_Intercept_defaultTransport(t *testing.T) {
+	// --- Given ---
+	prev := http.DefaultTransport
+
+	var cleanups []func()
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything).Run(func(args mock.Arguments) {
+		cleanups = append(cleanups, args.Get(0).(func()))
+	})
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck).Rsp(http.StatusOK, []byte("intercepted"))
+
+	// --- When ---
+	srv.Intercept(nil, "api.example.com")
+	rsp, err := http.Get("http://api.example.com/")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, "intercepted", string(getResponseBody(t, rsp)))
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	assert.True(t, prev == http.DefaultTransport)
+}
+
+func Test_HandlerTransport(t *testing.T) {
+	// --- Given ---
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte(r.Host + r.URL.Path))
+	})
+	cli := &http.Client{Transport: kit.HandlerTransport(h, "api.example.com")}
+
+	// --- When ---
+	rsp, err := cli.Get("http://api.example.com/pot")
+
+	// --- Then ---
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusTeapot, rsp.StatusCode)
+	assert.Exactly(t, "api.example.com/pot", string(getResponseBody(t, rsp)))
+}
@@ -0,0 +1,91 @@
+package testkit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// WithUnixSocket configures HTTPServer to listen on Unix domain socket
+// created in a new directory in os.TempDir(), removed on cleanup. The
+// directory name is kept short so the socket path fits the platform limit.
+// The server URL has "localhost" host, use Client() or Dialer() to connect
+// to the server. Calls t.Fatal() if the socket cannot be created.
+func WithUnixSocket() HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.t.Helper()
+		dir, err := os.MkdirTemp("", "tk")
+		if err != nil {
+			tst.t.Fatal(err)
+			return
+		}
+		tst.closers = append(tst.closers, func() { _ = os.RemoveAll(dir) })
+		tst.sock = filepath.Join(dir, "http.sock")
+	}
+}
+
+// WithListener configures HTTPServer to serve on listener l. The listener
+// is closed when the server is closed. For listeners other than TCP the
+// server URL has "localhost" host, use Client() or Dialer() to connect to
+// the server.
+func WithListener(l net.Listener) HTTPServerOption {
+	return func(tst *HTTPServer) { tst.lis = l }
+}
+
+// SocketPath returns path to the Unix domain socket the server listens on
+// or empty string if it does not listen on a Unix socket.
+func (tst *HTTPServer) SocketPath() string {
+	if tst.srv.Listener.Addr().Network() != "unix" {
+		return ""
+	}
+	return tst.srv.Listener.Addr().String()
+}
+
+// Dialer returns function dialing the server regardless of the requested
+// network and address. Use it as http.Transport.DialContext to connect to
+// the server listening on Unix socket or custom listener.
+func (tst *HTTPServer) Dialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	nw := tst.srv.Listener.Addr().Network()
+	addr := tst.srv.Listener.Addr().String()
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, nw, addr)
+	}
+}
+
+// listen replaces the default test server listener with Unix socket or
+// custom listener. Calls t.Fatal() on error.
+func (tst *HTTPServer) listen() {
+	tst.t.Helper()
+	lis := tst.lis
+	if tst.sock != "" {
+		var err error
+		if lis, err = net.Listen("unix", tst.sock); err != nil {
+			tst.t.Fatal(err)
+			return
+		}
+	}
+	if lis == nil {
+		return
+	}
+	_ = tst.srv.Listener.Close()
+	tst.srv.Listener = lis
+}
+
+// dial configures the server client to connect to non TCP listener and
+// sets the server URL host to "localhost".
+func (tst *HTTPServer) dial() {
+	if tst.srv.Listener.Addr().Network() == "tcp" {
+		return
+	}
+	if tr, ok := tst.srv.Client().Transport.(*http.Transport); ok {
+		tr.DialContext = tst.Dialer()
+	}
+	if tst.tls {
+		tst.srv.URL = "https://localhost"
+	} else {
+		tst.srv.URL = "http://localhost"
+	}
+}
@@ -0,0 +1,125 @@
+package testkit_test
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_WithUnixSocket(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		opts   []kit.HTTPServerOption
+		scheme string
+	}{
+		{"http", []kit.HTTPServerOption{kit.WithUnixSocket()}, "http"},
+		{"https", []kit.HTTPServerOption{kit.WithUnixSocket(), kit.WithTLS()}, "https"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			mck := &kit.TMock{}
+			mck.On("Cleanup", mock.Anything)
+			mck.On("Helper")
+
+			srv := kit.NewHTTPServer(mck, tc.opts...).Rsp(http.StatusOK, []byte("unix"))
+
+			// --- When ---
+			rsp, err := srv.Client().Post(
+				srv.URL()+"/containers/json",
+				"text/plain",
+				bytes.NewReader([]byte("body")),
+			)
+
+			// --- Then ---
+			mck.AssertExpectations(t)
+			require.NoError(t, err)
+			assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+			assert.Exactly(t, "unix", string(getResponseBody(t, rsp)))
+			assert.Exactly(t, tc.scheme+"://localhost", srv.URL())
+			assert.Exactly(t, "http.sock", filepath.Base(srv.SocketPath()))
+			assert.Exactly(t, "body", srv.BodyString(0))
+			assert.Exactly(t, "/containers/json", srv.Request(0).URL.Path)
+		})
+	}
+}
+
+func Test_HTTPServer_WithUnixSocket_cleanup(t *testing.T) {
+	// --- Given ---
+	var cleanups []func()
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything).Run(func(args mock.Arguments) {
+		cleanups = append(cleanups, args.Get(0).(func()))
+	})
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithUnixSocket())
+	dir := filepath.Dir(srv.SocketPath())
+
+	// --- When ---
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, filepath.Clean(os.TempDir()), filepath.Dir(dir))
+	_, err := os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_HTTPServer_WithListener(t *testing.T) {
+	// --- Given ---
+	lis, err := net.Listen("unix", filepath.Join(t.TempDir(), "custom.sock"))
+	require.NoError(t, err)
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithListener(lis)).Rsp(http.StatusAccepted, nil)
+	cli := &http.Client{Transport: &http.Transport{DialContext: srv.Dialer()}}
+
+	// --- When ---
+	rsp, err := cli.Get("http://docker/version")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusAccepted, rsp.StatusCode)
+	assert.Exactly(t, lis.Addr().String(), srv.SocketPath())
+	assert.Exactly(t, "/version", srv.Request(0).URL.Path)
+}
+
+func Test_HTTPServer_WithListener_TCP(t *testing.T) {
+	// --- Given ---
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithListener(lis)).Rsp(http.StatusOK, nil)
+
+	// --- When ---
+	rsp, err := http.Get("http://" + lis.Addr().String())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "http://"+lis.Addr().String(), srv.URL())
+	assert.Exactly(t, "", srv.SocketPath())
+}
@@ -0,0 +1,257 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Matcher represents HTTP request matcher used by HTTPServer routes.
+type Matcher struct {
+	desc  string                                      // Matcher description.
+	match func(req *http.Request, body []byte) bool   // Matching function.
+	recv  func(req *http.Request, body []byte) string // Received value description.
+	diff  func(body []byte) string                    // Body diff, may be nil.
+}
+
+// NewMatcher returns new request matcher with description desc. The fn
+// receives the request and its body and returns true if the request matches.
+// The fn may be called concurrently and may call HTTPServer methods.
+func NewMatcher(desc string, fn func(req *http.Request, body []byte) bool) Matcher {
+	return Matcher{desc: desc, match: fn}
+}
+
+// String returns matcher description.
+func (m Matcher) String() string { return m.desc }
+
+// Match returns true if request req with body matches.
+func (m Matcher) Match(req *http.Request, body []byte) bool {
+	return m.match(req, body)
+}
+
+// received returns description of the request value the matcher checks.
+// Returns empty string if matcher does not provide it.
+func (m Matcher) received(req *http.Request, body []byte) string {
+	if m.recv == nil {
+		return ""
+	}
+	return m.recv(req, body)
+}
+
+// MatchMethod returns matcher matching request HTTP method.
+func MatchMethod(method string) Matcher {
+	m := NewMatcher(
+		fmt.Sprintf("method %s", method),
+		func(req *http.Request, _ []byte) bool { return req.Method == method },
+	)
+	m.recv = func(req *http.Request, _ []byte) string {
+		return fmt.Sprintf("method %s", req.Method)
+	}
+	return m
+}
+
+// MatchPath returns matcher matching request URL path exactly.
+func MatchPath(pth string) Matcher {
+	m := NewMatcher(
+		fmt.Sprintf("path %s", pth),
+		func(req *http.Request, _ []byte) bool { return req.URL.Path == pth },
+	)
+	m.recv = recvPath
+	return m
+}
+
+// MatchPathPattern returns matcher matching request URL path against
+// regular expression pattern. Panics if pattern cannot be compiled.
+func MatchPathPattern(pattern string) Matcher {
+	r := regexp.MustCompile(pattern)
+	m := NewMatcher(
+		fmt.Sprintf("path pattern %s", pattern),
+		func(req *http.Request, _ []byte) bool {
+			return r.MatchString(req.URL.Path)
+		},
+	)
+	m.recv = recvPath
+	return m
+}
+
+// MatchQuery returns matcher matching requests which have URL query key with
+// all the provided values. When no values are given it only checks the key
+// is present.
+func MatchQuery(key string, values ...string) Matcher {
+	m := NewMatcher(
+		fmt.Sprintf("query %s=%v", key, values),
+		func(req *http.Request, _ []byte) bool {
+			got, ok := req.URL.Query()[key]
+			if !ok {
+				return false
+			}
+			return containsAll(got, values)
+		},
+	)
+	m.recv = func(req *http.Request, _ []byte) string {
+		return fmt.Sprintf("query %s=%v", key, req.URL.Query()[key])
+	}
+	return m
+}
+
+// MatchHeader returns matcher matching requests which have header key with
+// all the provided values. When no values are given it only checks the
+// header is present.
+func MatchHeader(key string, values ...string) Matcher {
+	key = http.CanonicalHeaderKey(key)
+	m := NewMatcher(
+		fmt.Sprintf("header %s=%v", key, values),
+		func(req *http.Request, _ []byte) bool {
+			got, ok := req.Header[key]
+			if !ok {
+				return false
+			}
+			return containsAll(got, values)
+		},
+	)
+	m.recv = func(req *http.Request, _ []byte) string {
+		return fmt.Sprintf("header %s=%v", key, req.Header[key])
+	}
+	return m
+}
+
+// MatchBody returns matcher matching request body with predicate fn.
+func MatchBody(fn func(body []byte) bool) Matcher {
+	m := NewMatcher(
+		"body predicate",
+		func(_ *http.Request, body []byte) bool { return fn(body) },
+	)
+	m.recv = func(_ *http.Request, body []byte) string {
+		return fmt.Sprintf("body %q", abbrev(body))
+	}
+	return m
+}
+
+// MatchBodyEqual returns matcher matching request body exactly.
+func MatchBodyEqual(exp []byte) Matcher {
+	m := NewMatcher(
+		fmt.Sprintf("body %q", abbrev(exp)),
+		func(_ *http.Request, body []byte) bool { return bytes.Equal(exp, body) },
+	)
+	m.recv = func(_ *http.Request, body []byte) string {
+		return fmt.Sprintf("body %q", abbrev(body))
+	}
+	m.diff = func(body []byte) string {
+		return unifiedDiff(string(exp), string(body))
+	}
+	return m
+}
+
+// MatchJSON returns matcher matching request JSON body with JSON
+// representation of v ignoring object field order and formatting. When v is
+// a byte slice or a string it is used as JSON document. Panics if v cannot
+// be represented as JSON.
+func MatchJSON(v interface{}) Matcher {
+	var exp interface{}
+	if err := json.Unmarshal(jsonBytes(v), &exp); err != nil {
+		panic(err)
+	}
+	expStr := normJSON(exp)
+
+	m := NewMatcher(
+		fmt.Sprintf("JSON body %s", abbrev([]byte(expStr))),
+		func(_ *http.Request, body []byte) bool {
+			var got interface{}
+			if err := json.Unmarshal(body, &got); err != nil {
+				return false
+			}
+			return reflect.DeepEqual(exp, got)
+		},
+	)
+	m.recv = func(_ *http.Request, body []byte) string {
+		var got interface{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			return fmt.Sprintf("invalid JSON body %q", abbrev(body))
+		}
+		return fmt.Sprintf("JSON body %s", abbrev([]byte(normJSON(got))))
+	}
+	m.diff = func(body []byte) string {
+		var got interface{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			return unifiedDiff(indentJSON(exp), string(body))
+		}
+		return unifiedDiff(indentJSON(exp), indentJSON(got))
+	}
+	return m
+}
+
+// jsonBytes returns JSON representation of v. Byte slices and strings are
+// returned as they are. Panics if v cannot be marshalled.
+func jsonBytes(v interface{}) []byte {
+	switch vv := v.(type) {
+	case []byte:
+		return vv
+	case string:
+		return []byte(vv)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// normJSON returns compact JSON with object keys sorted.
+func normJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// indentJSON returns indented JSON with object keys sorted.
+func indentJSON(v interface{}) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return string(data)
+}
+
+// abbrev returns at most first 64 bytes of b marking abbreviation with "...".
+func abbrev(b []byte) string {
+	const max = 64
+	if len(b) <= max {
+		return string(b)
+	}
+	return string(b[:max]) + "..."
+}
+
+// unifiedDiff returns unified diff between expected and received text.
+func unifiedDiff(exp, got string) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(exp),
+		B:        difflib.SplitLines(got),
+		FromFile: "expected",
+		ToFile:   "received",
+		Context:  3,
+	})
+	return diff
+}
+
+// recvPath describes request URL path.
+func recvPath(req *http.Request, _ []byte) string {
+	return fmt.Sprintf("path %s", req.URL.Path)
+}
+
+// containsAll returns true if all values are in the set.
+func containsAll(set, values []string) bool {
+	for _, v := range values {
+		var found bool
+		for _, s := range set {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
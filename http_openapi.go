@@ -0,0 +1,832 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// oaDepth is the maximum depth of schemas synthesized into examples.
+const oaDepth = 8
+
+// openAPI is a handler synthesizing responses from OpenAPI 3 document and
+// validating requests against it.
+type openAPI struct {
+	pth   string   // Path to the OpenAPI document.
+	doc   oaDoc    // The OpenAPI document.
+	tpls  []string // Sorted path templates.
+	bases []string // Base paths of the servers.
+	t     T        // Test state manager.
+}
+
+// oaDoc represents OpenAPI document.
+type oaDoc struct {
+	Servers    []oaServer             `yaml:"servers"`
+	Paths      map[string]*oaPathItem `yaml:"paths"`
+	Components oaComponents           `yaml:"components"`
+}
+
+// oaServer represents OpenAPI server.
+type oaServer struct {
+	URL string `yaml:"url"`
+}
+
+// oaComponents represents OpenAPI reusable components.
+type oaComponents struct {
+	Schemas       map[string]*oaSchema      `yaml:"schemas"`
+	Parameters    map[string]*oaParameter   `yaml:"parameters"`
+	RequestBodies map[string]*oaRequestBody `yaml:"requestBodies"`
+	Responses     map[string]*oaResponse    `yaml:"responses"`
+}
+
+// oaPathItem represents OpenAPI path item.
+type oaPathItem struct {
+	Parameters []*oaParameter `yaml:"parameters"`
+	Get        *oaOperation   `yaml:"get"`
+	Put        *oaOperation   `yaml:"put"`
+	Post       *oaOperation   `yaml:"post"`
+	Delete     *oaOperation   `yaml:"delete"`
+	Options    *oaOperation   `yaml:"options"`
+	Head       *oaOperation   `yaml:"head"`
+	Patch      *oaOperation   `yaml:"patch"`
+	Trace      *oaOperation   `yaml:"trace"`
+}
+
+// operation returns path item operation for the HTTP method or nil.
+func (pi *oaPathItem) operation(method string) *oaOperation {
+	switch method {
+	case http.MethodGet:
+		return pi.Get
+	case http.MethodPut:
+		return pi.Put
+	case http.MethodPost:
+		return pi.Post
+	case http.MethodDelete:
+		return pi.Delete
+	case http.MethodOptions:
+		return pi.Options
+	case http.MethodHead:
+		return pi.Head
+	case http.MethodPatch:
+		return pi.Patch
+	case http.MethodTrace:
+		return pi.Trace
+	}
+	return nil
+}
+
+// oaOperation represents OpenAPI operation.
+type oaOperation struct {
+	OperationID string                 `yaml:"operationId"`
+	Parameters  []*oaParameter         `yaml:"parameters"`
+	RequestBody *oaRequestBody         `yaml:"requestBody"`
+	Responses   map[string]*oaResponse `yaml:"responses"`
+}
+
+// oaParameter represents OpenAPI parameter.
+type oaParameter struct {
+	Ref      string    `yaml:"$ref"`
+	Name     string    `yaml:"name"`
+	In       string    `yaml:"in"`
+	Required bool      `yaml:"required"`
+	Schema   *oaSchema `yaml:"schema"`
+}
+
+// oaRequestBody represents OpenAPI request body.
+type oaRequestBody struct {
+	Ref      string                  `yaml:"$ref"`
+	Required bool                    `yaml:"required"`
+	Content  map[string]*oaMediaType `yaml:"content"`
+}
+
+// oaResponse represents OpenAPI response.
+type oaResponse struct {
+	Ref     string                  `yaml:"$ref"`
+	Content map[string]*oaMediaType `yaml:"content"`
+}
+
+// oaMediaType represents OpenAPI media type.
+type oaMediaType struct {
+	Schema   *oaSchema             `yaml:"schema"`
+	Example  interface{}           `yaml:"example"`
+	Examples map[string]*oaExample `yaml:"examples"`
+}
+
+// oaExample represents OpenAPI example.
+type oaExample struct {
+	Value interface{} `yaml:"value"`
+}
+
+// oaSchema represents OpenAPI schema object.
+type oaSchema struct {
+	Ref                  string               `yaml:"$ref"`
+	Type                 string               `yaml:"-"`
+	Format               string               `yaml:"format"`
+	Nullable             bool                 `yaml:"nullable"`
+	Enum                 []interface{}        `yaml:"enum"`
+	Properties           map[string]*oaSchema `yaml:"properties"`
+	Required             []string             `yaml:"required"`
+	AdditionalProperties *bool                `yaml:"-"`
+	Items                *oaSchema            `yaml:"items"`
+	MinItems             *int                 `yaml:"minItems"`
+	MaxItems             *int                 `yaml:"maxItems"`
+	MinLength            *int                 `yaml:"minLength"`
+	MaxLength            *int                 `yaml:"maxLength"`
+	Pattern              string               `yaml:"pattern"`
+	Minimum              *float64             `yaml:"minimum"`
+	Maximum              *float64             `yaml:"maximum"`
+	AllOf                []*oaSchema          `yaml:"allOf"`
+	AnyOf                []*oaSchema          `yaml:"anyOf"`
+	OneOf                []*oaSchema          `yaml:"oneOf"`
+	Example              interface{}          `yaml:"example"`
+	Default              interface{}          `yaml:"default"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. The additionalProperties
+// keyword is used only when it's a boolean. The type keyword may be a
+// string or OpenAPI 3.1 list of types where "null" makes the schema
+// nullable, the type is not validated if the list has more than one
+// other type.
+func (s *oaSchema) UnmarshalYAML(node *yaml.Node) error {
+	type plain oaSchema
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	var aux struct {
+		Type yaml.Node `yaml:"type"`
+		AP   yaml.Node `yaml:"additionalProperties"`
+	}
+	if err := node.Decode(&aux); err != nil {
+		return err
+	}
+	switch aux.Type.Kind {
+	case yaml.ScalarNode:
+		if err := aux.Type.Decode(&s.Type); err != nil {
+			return err
+		}
+	case yaml.SequenceNode:
+		var types []string
+		if err := aux.Type.Decode(&types); err != nil {
+			return err
+		}
+		var other []string
+		for _, typ := range types {
+			if typ == "null" {
+				s.Nullable = true
+				continue
+			}
+			other = append(other, typ)
+		}
+		if len(other) == 1 {
+			s.Type = other[0]
+		}
+	}
+	if aux.AP.Kind == yaml.ScalarNode {
+		var ap bool
+		if err := aux.AP.Decode(&ap); err == nil {
+			s.AdditionalProperties = &ap
+		}
+	}
+	return nil
+}
+
+// WithOpenAPI configures HTTPServer to validate every received request
+// against OpenAPI 3.0 or 3.1 document (YAML or JSON) at pth. The request
+// path, method, path, query and header parameters and the JSON request body
+// are validated, violations are reported with t.Errorf(). In 3.1 documents
+// schema type may be a list of types, "null" in the list makes the schema
+// nullable.
+//
+// Requests without responses are answered with responses synthesized from
+// the document: the first success response (or default) with the example
+// defined in the document or built from the response schema. Requests to
+// paths or methods not in the document are answered with status 404 and
+// 405. Calls t.Fatal() if the document cannot be loaded.
+func WithOpenAPI(pth string) HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.t.Helper()
+		oa := &openAPI{pth: pth, t: tst.t}
+		if err := yaml.Unmarshal(ReadFile(tst.t, pth), &oa.doc); err != nil {
+			tst.t.Fatal(err)
+			return
+		}
+		for tpl := range oa.doc.Paths {
+			oa.tpls = append(oa.tpls, tpl)
+		}
+		sort.Strings(oa.tpls)
+		for _, srv := range oa.doc.Servers {
+			if u, err := url.Parse(srv.URL); err == nil && strings.Trim(u.Path, "/") != "" {
+				oa.bases = append(oa.bases, "/"+strings.Trim(u.Path, "/"))
+			}
+		}
+		oa.bases = append(oa.bases, "")
+		tst.openapi = oa
+		tst.fallback = oa
+	}
+}
+
+// ServeHTTP implements http.Handler synthesizing response from the document.
+func (oa *openAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	item, _, ok := oa.find(req.URL.Path)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	op := item.operation(req.Method)
+	if op == nil {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, rsp := oa.response(op)
+	if rsp == nil || len(rsp.Content) == 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	typ := "application/json"
+	if _, ok := rsp.Content[typ]; !ok {
+		typ = oaKeys(rsp.Content)[0]
+	}
+	val := oa.exampleMedia(rsp.Content[typ])
+
+	var body []byte
+	if s, ok := val.(string); ok && !oaJSON(typ) {
+		body = []byte(s)
+	} else {
+		var err error
+		if body, err = json.Marshal(val); err != nil {
+			oa.t.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	if !strings.Contains(typ, "*") {
+		w.Header().Set("Content-Type", typ)
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// validate validates request against the document. Violations are
+// reported with t.Errorf().
+func (oa *openAPI) validate(req *http.Request, body []byte) {
+	var vs []string
+	item, params, ok := oa.find(req.URL.Path)
+	switch {
+	case !ok:
+		vs = append(vs, "path not found")
+	case item.operation(req.Method) == nil:
+		vs = append(vs, "method not allowed")
+	default:
+		op := item.operation(req.Method)
+		vs = append(vs, oa.validateParams(item, op, req, params)...)
+		vs = append(vs, oa.validateBody(op, req, body)...)
+	}
+	if len(vs) == 0 {
+		return
+	}
+	oa.t.Errorf(
+		"request %s %s violates OpenAPI document %s:\n  %s",
+		req.Method,
+		req.URL.RequestURI(),
+		oa.pth,
+		strings.Join(vs, "\n  "),
+	)
+}
+
+// validateParams validates request parameters. Returns violations.
+func (oa *openAPI) validateParams(item *oaPathItem, op *oaOperation, req *http.Request, params map[string]string) []string {
+	// Operation parameters override path item parameters.
+	var ps []*oaParameter
+	seen := make(map[string]bool)
+	for _, p := range append(append([]*oaParameter{}, op.Parameters...), item.Parameters...) {
+		if p = oa.parameter(p); p == nil {
+			continue
+		}
+		key := p.In + ":" + p.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ps = append(ps, p)
+	}
+
+	var vs []string
+	for _, p := range ps {
+		var raw []string
+		switch p.In {
+		case "path":
+			if v, ok := params[p.Name]; ok {
+				raw = []string{v}
+			}
+		case "query":
+			raw = req.URL.Query()[p.Name]
+		case "header":
+			raw = req.Header.Values(p.Name)
+		case "cookie":
+			if c, err := req.Cookie(p.Name); err == nil {
+				raw = []string{c.Value}
+			}
+		}
+
+		at := p.In + " parameter " + p.Name
+		if len(raw) == 0 {
+			if p.Required || p.In == "path" {
+				vs = append(vs, at+": required")
+			}
+			continue
+		}
+		val, err := oa.parseParam(p, raw)
+		if err != nil {
+			vs = append(vs, fmt.Sprintf("%s: %s", at, err))
+			continue
+		}
+		vs = append(vs, oa.check(p.Schema, val, at, 0)...)
+	}
+	return vs
+}
+
+// validateBody validates request body. Returns violations.
+func (oa *openAPI) validateBody(op *oaOperation, req *http.Request, body []byte) []string {
+	rb := oa.requestBody(op.RequestBody)
+	if rb == nil {
+		return nil
+	}
+	if len(body) == 0 {
+		if rb.Required {
+			return []string{"body: required"}
+		}
+		return nil
+	}
+
+	typ, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	mt := oaMedia(rb.Content, typ)
+	if mt == nil {
+		return []string{fmt.Sprintf("body: unsupported content type %q", typ)}
+	}
+	if mt.Schema == nil || !oaJSON(typ) {
+		return nil
+	}
+	var val interface{}
+	if err := json.Unmarshal(body, &val); err != nil {
+		return []string{"body: invalid JSON: " + err.Error()}
+	}
+	return oa.check(mt.Schema, val, "body", 0)
+}
+
+// find returns path item matching request path and path parameter values.
+// Paths with more literal segments take precedence. Returns false if no
+// path matches.
+func (oa *openAPI) find(pth string) (*oaPathItem, map[string]string, bool) {
+	for _, base := range oa.bases {
+		if !strings.HasPrefix(pth, base) {
+			continue
+		}
+		segs := strings.Split(strings.TrimPrefix(pth, base), "/")
+		var best map[string]string
+		var item *oaPathItem
+		for _, tpl := range oa.tpls {
+			params, ok := oaMatchPath(strings.Split(tpl, "/"), segs)
+			if !ok || (item != nil && len(params) >= len(best)) {
+				continue
+			}
+			best, item = params, oa.doc.Paths[tpl]
+		}
+		if item != nil {
+			return item, best, true
+		}
+	}
+	return nil, nil, false
+}
+
+// oaMatchPath matches path segments against template segments. Returns
+// path parameter values and true on match.
+func oaMatchPath(tpl, segs []string) (map[string]string, bool) {
+	if len(tpl) != len(segs) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, t := range tpl {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segs[i] == "" {
+				return nil, false
+			}
+			params[t[1:len(t)-1]] = segs[i]
+			continue
+		}
+		if t != segs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// response returns status and response for the first success response of
+// the operation, or the default one.
+func (oa *openAPI) response(op *oaOperation) (int, *oaResponse) {
+	for _, code := range oaKeys(op.Responses) {
+		if strings.HasPrefix(code, "2") {
+			status, err := strconv.Atoi(code)
+			if err != nil {
+				status = http.StatusOK
+			}
+			return status, oa.resolveResponse(op.Responses[code])
+		}
+	}
+	if rsp, ok := op.Responses["default"]; ok {
+		return http.StatusOK, oa.resolveResponse(rsp)
+	}
+	return http.StatusOK, nil
+}
+
+// parseParam parses raw parameter values according to parameter schema.
+func (oa *openAPI) parseParam(p *oaParameter, raw []string) (interface{}, error) {
+	s := oa.schema(p.Schema)
+	if s == nil {
+		return raw[0], nil
+	}
+	if s.Type != "array" {
+		return oaParseValue(s, raw[0])
+	}
+	if len(raw) == 1 && p.In != "query" {
+		raw = strings.Split(raw[0], ",")
+	}
+	vals := make([]interface{}, 0, len(raw))
+	for _, r := range raw {
+		v, err := oaParseValue(oa.schema(s.Items), r)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// oaParseValue parses raw parameter value according to schema type.
+func oaParseValue(s *oaSchema, raw string) (interface{}, error) {
+	if s == nil {
+		return raw, nil
+	}
+	switch s.Type {
+	case "integer":
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected integer got %q", raw)
+		}
+		return float64(v), nil
+	case "number":
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected number got %q", raw)
+		}
+		return v, nil
+	case "boolean":
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected boolean got %q", raw)
+		}
+		return v, nil
+	}
+	return raw, nil
+}
+
+// check validates value v decoded from JSON against schema s. The at is
+// the location of the value used in violation descriptions.
+func (oa *openAPI) check(s *oaSchema, v interface{}, at string, depth int) []string {
+	if s == nil || depth > 64 {
+		return nil
+	}
+	if s.Ref != "" {
+		rs := oa.schema(s)
+		if rs == nil {
+			return []string{fmt.Sprintf("%s: unresolved reference %s", at, s.Ref)}
+		}
+		return oa.check(rs, v, at, depth+1)
+	}
+
+	var vs []string
+	for _, sub := range s.AllOf {
+		vs = append(vs, oa.check(sub, v, at, depth+1)...)
+	}
+	if len(s.AnyOf) > 0 {
+		var ok bool
+		for _, sub := range s.AnyOf {
+			if len(oa.check(sub, v, at, depth+1)) == 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			vs = append(vs, at+": does not match any schema")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		var cnt int
+		for _, sub := range s.OneOf {
+			if len(oa.check(sub, v, at, depth+1)) == 0 {
+				cnt++
+			}
+		}
+		if cnt != 1 {
+			vs = append(vs, fmt.Sprintf("%s: matches %d schemas expected exactly one", at, cnt))
+		}
+	}
+
+	if v == nil {
+		if !s.Nullable && s.Type != "" {
+			vs = append(vs, fmt.Sprintf("%s: expected %s got null", at, s.Type))
+		}
+		return vs
+	}
+	if len(s.Enum) > 0 && !oaEnum(s.Enum, v) {
+		vs = append(vs, fmt.Sprintf("%s: value %s not in enum", at, oaString(v)))
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return append(vs, fmt.Sprintf("%s: expected object got %s", at, oaType(v)))
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				vs = append(vs, fmt.Sprintf("%s.%s: required", at, name))
+			}
+		}
+		for _, name := range oaKeys(obj) {
+			ps, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					vs = append(vs, fmt.Sprintf("%s.%s: unexpected property", at, name))
+				}
+				continue
+			}
+			vs = append(vs, oa.check(ps, obj[name], at+"."+name, depth+1)...)
+		}
+
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return append(vs, fmt.Sprintf("%s: expected array got %s", at, oaType(v)))
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			vs = append(vs, fmt.Sprintf("%s: expected at least %d items got %d", at, *s.MinItems, len(arr)))
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			vs = append(vs, fmt.Sprintf("%s: expected at most %d items got %d", at, *s.MaxItems, len(arr)))
+		}
+		for i, item := range arr {
+			vs = append(vs, oa.check(s.Items, item, fmt.Sprintf("%s[%d]", at, i), depth+1)...)
+		}
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return append(vs, fmt.Sprintf("%s: expected string got %s", at, oaType(v)))
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			vs = append(vs, fmt.Sprintf("%s: expected at least %d characters got %d", at, *s.MinLength, n))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			vs = append(vs, fmt.Sprintf("%s: expected at most %d characters got %d", at, *s.MaxLength, n))
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(str) {
+				vs = append(vs, fmt.Sprintf("%s: %q does not match pattern %s", at, str, s.Pattern))
+			}
+		}
+
+	case "integer", "number":
+		num, ok := v.(float64)
+		if !ok {
+			return append(vs, fmt.Sprintf("%s: expected %s got %s", at, s.Type, oaType(v)))
+		}
+		if s.Type == "integer" && num != float64(int64(num)) {
+			return append(vs, fmt.Sprintf("%s: expected integer got %v", at, num))
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			vs = append(vs, fmt.Sprintf("%s: expected at least %v got %v", at, *s.Minimum, num))
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			vs = append(vs, fmt.Sprintf("%s: expected at most %v got %v", at, *s.Maximum, num))
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return append(vs, fmt.Sprintf("%s: expected boolean got %s", at, oaType(v)))
+		}
+	}
+	return vs
+}
+
+// exampleMedia returns example value for the media type.
+func (oa *openAPI) exampleMedia(mt *oaMediaType) interface{} {
+	if mt.Example != nil {
+		return mt.Example
+	}
+	for _, name := range oaKeys(mt.Examples) {
+		if ex := mt.Examples[name]; ex != nil && ex.Value != nil {
+			return ex.Value
+		}
+	}
+	return oa.example(mt.Schema, 0)
+}
+
+// example returns example value built from the schema.
+func (oa *openAPI) example(s *oaSchema, depth int) interface{} {
+	if s = oa.schema(s); s == nil || depth > oaDepth {
+		return nil
+	}
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.OneOf) > 0:
+		return oa.example(s.OneOf[0], depth+1)
+	case len(s.AnyOf) > 0:
+		return oa.example(s.AnyOf[0], depth+1)
+	case len(s.AllOf) > 0:
+		obj := make(map[string]interface{})
+		for _, sub := range s.AllOf {
+			if m, ok := oa.example(sub, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					obj[k] = v
+				}
+			}
+		}
+		return obj
+	}
+
+	switch s.Type {
+	case "array":
+		return []interface{}{oa.example(s.Items, depth+1)}
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "1970-01-01T00:00:00Z"
+		case "date":
+			return "1970-01-01"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "email":
+			return "user@example.com"
+		case "uri":
+			return "https://example.com"
+		}
+		return "string"
+	case "integer":
+		if s.Minimum != nil {
+			return int64(*s.Minimum)
+		}
+		return 0
+	case "number":
+		if s.Minimum != nil {
+			return *s.Minimum
+		}
+		return 0.0
+	case "boolean":
+		return false
+	}
+
+	obj := make(map[string]interface{}, len(s.Properties))
+	for name, ps := range s.Properties {
+		obj[name] = oa.example(ps, depth+1)
+	}
+	return obj
+}
+
+// schema returns schema with resolved reference. Returns nil if reference
+// cannot be resolved.
+func (oa *openAPI) schema(s *oaSchema) *oaSchema {
+	for i := 0; s != nil && s.Ref != "" && i < oaDepth; i++ {
+		s = oa.doc.Components.Schemas[oaRefName(s.Ref, "schemas")]
+	}
+	if s != nil && s.Ref != "" {
+		return nil
+	}
+	return s
+}
+
+// parameter returns parameter with resolved reference.
+func (oa *openAPI) parameter(p *oaParameter) *oaParameter {
+	if p != nil && p.Ref != "" {
+		return oa.doc.Components.Parameters[oaRefName(p.Ref, "parameters")]
+	}
+	return p
+}
+
+// requestBody returns request body with resolved reference.
+func (oa *openAPI) requestBody(rb *oaRequestBody) *oaRequestBody {
+	if rb != nil && rb.Ref != "" {
+		return oa.doc.Components.RequestBodies[oaRefName(rb.Ref, "requestBodies")]
+	}
+	return rb
+}
+
+// resolveResponse returns response with resolved reference.
+func (oa *openAPI) resolveResponse(rsp *oaResponse) *oaResponse {
+	if rsp != nil && rsp.Ref != "" {
+		return oa.doc.Components.Responses[oaRefName(rsp.Ref, "responses")]
+	}
+	return rsp
+}
+
+// oaRefName returns component name from local reference to components of
+// the kind. Returns empty string for other references.
+func oaRefName(ref, kind string) string {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(ref, prefix)
+}
+
+// oaMedia returns media type for content type typ. Wildcard media types
+// like "application/*" and "*/*" are supported.
+func oaMedia(content map[string]*oaMediaType, typ string) *oaMediaType {
+	if mt, ok := content[typ]; ok {
+		return mt
+	}
+	if i := strings.Index(typ, "/"); i > 0 {
+		if mt, ok := content[typ[:i]+"/*"]; ok {
+			return mt
+		}
+	}
+	return content["*/*"]
+}
+
+// oaJSON returns true if content type typ is JSON.
+func oaJSON(typ string) bool {
+	return typ == "application/json" || strings.HasSuffix(typ, "+json")
+}
+
+// oaEnum returns true if v is one of enum values.
+func oaEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(oaNorm(e), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// oaNorm returns value decoded from YAML as if it was decoded from JSON.
+func oaNorm(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var n interface{}
+	if err := json.Unmarshal(data, &n); err != nil {
+		return v
+	}
+	return n
+}
+
+// oaType returns JSON type name of value v decoded from JSON.
+func oaType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// oaString returns value v as JSON.
+func oaString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// oaKeys returns sorted keys of the map with string keys.
+func oaKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
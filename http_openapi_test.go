@@ -0,0 +1,331 @@
+package testkit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+// openAPISpec is OpenAPI document used in tests.
+const openAPISpec = `
+openapi: 3.0.0
+info:
+  title: Users
+  version: "1"
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            maximum: 100
+      responses:
+        "200":
+          description: List of users.
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/User"
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/User"
+      responses:
+        "201":
+          description: Created user.
+          content:
+            application/json:
+              example: {"id": 7, "name": "Bob"}
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+    delete:
+      responses:
+        "204":
+          description: Deleted.
+components:
+  schemas:
+    User:
+      type: object
+      additionalProperties: false
+      required: [name]
+      properties:
+        id:
+          type: integer
+        name:
+          type: string
+          minLength: 2
+        role:
+          type: string
+          enum: [admin, user]
+          default: user
+`
+
+// writeOpenAPISpec writes openAPISpec to temporary directory and returns
+// its path.
+func writeOpenAPISpec(t *testing.T) string {
+	pth := filepath.Join(t.TempDir(), "openapi.yaml")
+	require.NoError(t, ioutil.WriteFile(pth, []byte(openAPISpec), 0644))
+	return pth
+}
+
+func Test_HTTPServer_WithOpenAPI_synthesize(t *testing.T) {
+	// --- Given ---
+	pth := writeOpenAPISpec(t)
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithOpenAPI(pth))
+	usr := []byte(`{"name":"Bob"}`)
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL() + "/v1/users?limit=10")
+	rsp1, err1 := http.Post(srv.URL()+"/v1/users", "application/json", bytes.NewReader(usr))
+	req, err := http.NewRequest(http.MethodDelete, srv.URL()+"/users/7", nil)
+	require.NoError(t, err)
+	rsp2, err2 := http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, "application/json", rsp0.Header.Get("Content-Type"))
+	exp := `[{"id":0,"name":"string","role":"user"}]`
+	assert.JSONEq(t, exp, string(getResponseBody(t, rsp0)))
+
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusCreated, rsp1.StatusCode)
+	assert.JSONEq(t, `{"id":7,"name":"Bob"}`, string(getResponseBody(t, rsp1)))
+
+	require.NoError(t, err2)
+	assert.Exactly(t, http.StatusNoContent, rsp2.StatusCode)
+}
+
+func Test_HTTPServer_WithOpenAPI_responsesTakePrecedence(t *testing.T) {
+	// --- Given ---
+	pth := writeOpenAPISpec(t)
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithOpenAPI(pth))
+	srv.Rsp(http.StatusOK, []byte(`[]`))
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL() + "/users")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "[]", string(getResponseBody(t, rsp)))
+}
+
+func Test_HTTPServer_WithOpenAPI_invalidRequest(t *testing.T) {
+	// --- Given ---
+	pth := writeOpenAPISpec(t)
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"request %s %s violates OpenAPI document %s:\n  %s",
+		http.MethodPost,
+		"/users",
+		pth,
+		"body.age: unexpected property\n"+
+			"  body.name: expected at least 2 characters got 1\n"+
+			"  body.role: value \"root\" not in enum",
+	)
+
+	srv := kit.NewHTTPServer(mck, kit.WithOpenAPI(pth))
+	usr := []byte(`{"name":"B","role":"root","age":3}`)
+
+	// --- When ---
+	rsp, err := http.Post(srv.URL()+"/users", "application/json", bytes.NewReader(usr))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusCreated, rsp.StatusCode)
+}
+
+func Test_HTTPServer_WithOpenAPI_31typeList(t *testing.T) {
+	// --- Given ---
+	spec := `
+openapi: 3.1.0
+info:
+  title: Users
+  version: "1"
+paths:
+  /users:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                nick:
+                  type: [string, "null"]
+                age:
+                  type: ["null", integer]
+                any:
+                  type: [string, integer]
+      responses:
+        "201":
+          description: Created user.
+`
+	pth := filepath.Join(t.TempDir(), "openapi.yaml")
+	require.NoError(t, ioutil.WriteFile(pth, []byte(spec), 0644))
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"request %s %s violates OpenAPI document %s:\n  %s",
+		http.MethodPost,
+		"/users",
+		pth,
+		"body.age: expected integer got string",
+	)
+
+	srv := kit.NewHTTPServer(mck, kit.WithOpenAPI(pth))
+	body0 := []byte(`{"nick":null,"age":null,"any":1}`)
+	body1 := []byte(`{"nick":"bob","age":"x","any":"y"}`)
+
+	// --- When ---
+	rsp0, err0 := http.Post(srv.URL()+"/users", "application/json", bytes.NewReader(body0))
+	rsp1, err1 := http.Post(srv.URL()+"/users", "application/json", bytes.NewReader(body1))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusCreated, rsp0.StatusCode)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusCreated, rsp1.StatusCode)
+}
+
+func Test_HTTPServer_WithOpenAPI_violations(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		method string
+		path   string
+		ct     string
+		body   string
+		status int
+		exp    string
+	}{
+		{
+			"unknown path",
+			http.MethodGet, "/orders", "", "",
+			http.StatusNotFound,
+			"path not found",
+		},
+		{
+			"unknown method",
+			http.MethodPut, "/users", "", "",
+			http.StatusMethodNotAllowed,
+			"method not allowed",
+		},
+		{
+			"invalid query parameter",
+			http.MethodGet, "/users?limit=abc", "", "",
+			http.StatusOK,
+			"query parameter limit: expected integer got \"abc\"",
+		},
+		{
+			"query parameter out of range",
+			http.MethodGet, "/users?limit=101", "", "",
+			http.StatusOK,
+			"query parameter limit: expected at most 100 got 101",
+		},
+		{
+			"invalid path parameter",
+			http.MethodDelete, "/users/abc", "", "",
+			http.StatusNoContent,
+			"path parameter id: expected integer got \"abc\"",
+		},
+		{
+			"missing body",
+			http.MethodPost, "/users", "application/json", "",
+			http.StatusCreated,
+			"body: required",
+		},
+		{
+			"invalid content type",
+			http.MethodPost, "/users", "text/plain", "Bob",
+			http.StatusCreated,
+			"body: unsupported content type \"text/plain\"",
+		},
+		{
+			"missing required property",
+			http.MethodPost, "/users", "application/json", `{"id":1.5}`,
+			http.StatusCreated,
+			"body.name: required\n  body.id: expected integer got 1.5",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			pth := writeOpenAPISpec(t)
+
+			mck := &kit.TMock{}
+			mck.On("Cleanup", mock.Anything)
+			mck.On("Helper")
+			mck.On(
+				"Errorf",
+				"request %s %s violates OpenAPI document %s:\n  %s",
+				tc.method,
+				tc.path,
+				pth,
+				tc.exp,
+			)
+
+			srv := kit.NewHTTPServer(mck, kit.WithOpenAPI(pth))
+
+			req, err := http.NewRequest(tc.method, srv.URL()+tc.path, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			if tc.ct != "" {
+				req.Header.Set("Content-Type", tc.ct)
+			}
+
+			// --- When ---
+			rsp, err := http.DefaultClient.Do(req)
+
+			// --- Then ---
+			mck.AssertExpectations(t)
+			require.NoError(t, err)
+			assert.Exactly(t, tc.status, rsp.StatusCode)
+		})
+	}
+}
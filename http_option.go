@@ -0,0 +1,29 @@
+package testkit
+
+// HTTPServerOption represents HTTPServer configuration option.
+type HTTPServerOption func(*HTTPServer)
+
+// WithTLS configures HTTPServer to serve HTTPS using certificate issued
+// by throwaway certificate authority. See HTTPServer.CA().
+func WithTLS() HTTPServerOption {
+	return func(tst *HTTPServer) { tst.tls = true }
+}
+
+// WithMTLS configures HTTPServer to serve HTTPS and require clients to
+// present certificate signed by the server's certificate authority. The
+// client returned by HTTPServer.Client() presents certificate with common
+// name "client", use HTTPServer.ClientCert() to issue more.
+func WithMTLS() HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.tls = true
+		tst.mtls = true
+	}
+}
+
+// WithHTTP2 enables HTTP/2 on HTTPServer. In TLS mode the protocol is
+// negotiated with ALPN, otherwise the server accepts HTTP/2 over cleartext
+// (h2c) with prior knowledge alongside HTTP/1.1. The client returned by
+// HTTPServer.Client() is configured to use HTTP/2.
+func WithHTTP2() HTTPServerOption {
+	return func(tst *HTTPServer) { tst.http2 = true }
+}
@@ -0,0 +1,157 @@
+package testkit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// limiter represents rate limiting policy.
+type limiter interface {
+	// take takes a token for request received at now and returns rate
+	// limit state. Must be called with the server lock held.
+	take(now time.Time) rateState
+}
+
+// rateState represents rate limit state after taking a token.
+type rateState struct {
+	limit     int           // Maximum number of requests.
+	remaining int           // Remaining number of requests.
+	reset     time.Time     // The time limit resets.
+	retry     time.Duration // Time to wait before retrying throttled request.
+	throttled bool          // Request exceeded the limit.
+}
+
+// header sets X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (Unix time in seconds) headers.
+func (st rateState) header(hdr http.Header) {
+	hdr.Set("X-RateLimit-Limit", strconv.Itoa(st.limit))
+	hdr.Set("X-RateLimit-Remaining", strconv.Itoa(st.remaining))
+	hdr.Set("X-RateLimit-Reset", strconv.FormatInt(ceilUnix(st.reset), 10))
+}
+
+// reject writes status 429 with Retry-After header in seconds.
+func (st rateState) reject(w http.ResponseWriter) {
+	secs := int64(math.Ceil(st.retry.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// WithTokenBucket configures HTTPServer to limit requests with token
+// bucket holding at most burst tokens refilled with one token every
+// duration. The bucket is full when the server starts.
+//
+// Requests exceeding the limit are answered with status 429 and
+// Retry-After header, they don't use added responses and are not counted
+// as expected requests. All responses have X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset headers. Use Throttled to
+// check which requests were rejected and At to check when they were made.
+func WithTokenBucket(burst int, every time.Duration) HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.limiter = &tokenBucket{burst: burst, every: every, tokens: float64(burst)}
+	}
+}
+
+// WithFixedWindow configures HTTPServer to allow at most limit requests
+// in fixed time windows. The first window starts with the first request,
+// the next one with the first request after the previous window ended.
+// See WithTokenBucket for details how the requests exceeding the limit
+// are answered.
+func WithFixedWindow(limit int, window time.Duration) HTTPServerOption {
+	return func(tst *HTTPServer) {
+		tst.limiter = &fixedWindow{limit: limit, window: window}
+	}
+}
+
+// Throttled returns true if the nth request was rejected by the rate
+// limiter. Calls t.Fatal() if n is greater than number or received
+// requests.
+func (tst *HTTPServer) Throttled(n int) bool {
+	tst.t.Helper()
+	rec, _ := tst.record(n)
+	return rec.thr
+}
+
+// ThrottledCount returns number of requests rejected by the rate limiter.
+func (tst *HTTPServer) ThrottledCount() int {
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
+	var cnt int
+	for _, rec := range tst.requests {
+		if rec.thr {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// tokenBucket represents token bucket rate limiter.
+type tokenBucket struct {
+	burst  int           // Bucket capacity.
+	every  time.Duration // Token refill interval.
+	tokens float64       // Tokens in the bucket.
+	last   time.Time     // Last refill time.
+}
+
+// take implements limiter.
+func (tb *tokenBucket) take(now time.Time) rateState {
+	if !tb.last.IsZero() && now.After(tb.last) {
+		tb.tokens += float64(now.Sub(tb.last)) / float64(tb.every)
+		if tb.tokens > float64(tb.burst) {
+			tb.tokens = float64(tb.burst)
+		}
+	}
+	if tb.last.IsZero() || now.After(tb.last) {
+		tb.last = now
+	}
+
+	st := rateState{limit: tb.burst}
+	if tb.tokens >= 1 {
+		tb.tokens--
+	} else {
+		st.throttled = true
+		st.retry = time.Duration((1 - tb.tokens) * float64(tb.every))
+	}
+	st.remaining = int(tb.tokens)
+	st.reset = now.Add(time.Duration((float64(tb.burst) - tb.tokens) * float64(tb.every)))
+	return st
+}
+
+// fixedWindow represents fixed window rate limiter.
+type fixedWindow struct {
+	limit  int           // Maximum number of requests in the window.
+	window time.Duration // Window duration.
+	start  time.Time     // Current window start.
+	used   int           // Requests made in the current window.
+}
+
+// take implements limiter.
+func (fw *fixedWindow) take(now time.Time) rateState {
+	if fw.start.IsZero() || now.Sub(fw.start) >= fw.window {
+		fw.start, fw.used = now, 0
+	}
+	end := fw.start.Add(fw.window)
+
+	st := rateState{limit: fw.limit, reset: end}
+	if fw.used < fw.limit {
+		fw.used++
+	} else {
+		st.throttled = true
+		st.retry = end.Sub(now)
+	}
+	st.remaining = fw.limit - fw.used
+	return st
+}
+
+// ceilUnix returns Unix time in seconds rounded up.
+func ceilUnix(tm time.Time) int64 {
+	sec := tm.Unix()
+	if tm.Nanosecond() > 0 {
+		sec++
+	}
+	return sec
+}
@@ -0,0 +1,92 @@
+package testkit_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_WithTokenBucket(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithTokenBucket(2, 100*time.Millisecond))
+	srv.Rsp(http.StatusOK, nil).Rsp(http.StatusOK, nil).Rsp(http.StatusOK, nil)
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL())
+	rsp1, err1 := http.Get(srv.URL())
+	rsp2, err2 := http.Get(srv.URL())
+	time.Sleep(120 * time.Millisecond)
+	rsp3, err3 := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, "2", rsp0.Header.Get("X-RateLimit-Limit"))
+	assert.Exactly(t, "1", rsp0.Header.Get("X-RateLimit-Remaining"))
+	reset, err := strconv.ParseInt(rsp0.Header.Get("X-RateLimit-Reset"), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, reset >= time.Now().Unix())
+
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusOK, rsp1.StatusCode)
+	assert.Exactly(t, "0", rsp1.Header.Get("X-RateLimit-Remaining"))
+
+	require.NoError(t, err2)
+	assert.Exactly(t, http.StatusTooManyRequests, rsp2.StatusCode)
+	assert.Exactly(t, "1", rsp2.Header.Get("Retry-After"))
+	assert.Exactly(t, "0", rsp2.Header.Get("X-RateLimit-Remaining"))
+
+	require.NoError(t, err3)
+	assert.Exactly(t, http.StatusOK, rsp3.StatusCode)
+
+	assert.Exactly(t, 4, srv.ReqCount())
+	assert.Exactly(t, 1, srv.ThrottledCount())
+	assert.False(t, srv.Throttled(0))
+	assert.True(t, srv.Throttled(2))
+	assert.False(t, srv.Throttled(3))
+	assert.True(t, srv.At(3).Sub(srv.At(2)) >= 100*time.Millisecond)
+}
+
+func Test_HTTPServer_WithFixedWindow(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithFixedWindow(1, time.Hour))
+	srv.Rsp(http.StatusOK, nil)
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL())
+	rsp1, err1 := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, "1", rsp0.Header.Get("X-RateLimit-Limit"))
+	assert.Exactly(t, "0", rsp0.Header.Get("X-RateLimit-Remaining"))
+
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusTooManyRequests, rsp1.StatusCode)
+	retry, err := strconv.Atoi(rsp1.Header.Get("Retry-After"))
+	require.NoError(t, err)
+	assert.True(t, retry > 3500 && retry <= 3600)
+
+	assert.True(t, srv.Throttled(1))
+	assert.Exactly(t, 1, srv.ThrottledCount())
+}
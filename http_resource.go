@@ -0,0 +1,438 @@
+package testkit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Resource represents in-memory REST resource mounted on HTTPServer.
+//
+// The resource stores JSON objects identified by the value of the ID
+// field and implements:
+//
+//	GET    /path       - list objects, 200
+//	POST   /path       - create object, 201 or 409 if ID exists
+//	GET    /path/{id}  - get object, 200 or 304 for matching If-None-Match
+//	PUT    /path/{id}  - replace object, 200 or 201 when created
+//	PATCH  /path/{id}  - update object with JSON merge patch, 200
+//	DELETE /path/{id}  - delete object, 204
+//
+// Requests for missing objects are answered with status 404, requests
+// with invalid JSON objects with status 400. Single object responses have
+// ETag header, PUT, PATCH and DELETE requests with not matching If-Match
+// header are answered with status 412.
+//
+// The list is paginated with "limit" and "offset" query parameters. The
+// total number of objects is returned in X-Total-Count header and the next
+// page URL in the Link header.
+type Resource struct {
+	path  string                            // Collection path.
+	idf   string                            // ID field name.
+	t     T                                 // Test state manager.
+	mx    sync.Mutex                        // Guards fields below.
+	ids   []string                          // Object IDs in insertion order.
+	objs  map[string]map[string]interface{} // Objects by ID.
+	seq   int                               // Last generated ID.
+	limit int                               // Default page size.
+}
+
+// Resource adds route serving in-memory REST resource at collection path
+// pth with objects identified by field idf and returns it. Requests
+// answered by the resource are not counted as expected requests.
+func (tst *HTTPServer) Resource(pth, idf string) *Resource {
+	res := &Resource{
+		path: "/" + strings.Trim(pth, "/"),
+		idf:  idf,
+		t:    tst.t,
+		objs: make(map[string]map[string]interface{}),
+	}
+	m := NewMatcher("resource "+res.path, func(req *http.Request, _ []byte) bool {
+		_, ok := res.id(req.URL.Path)
+		return ok
+	})
+	tst.Route(m).Handler(res)
+	return res
+}
+
+// PageSize sets default number of objects returned when listing the
+// resource without "limit" query parameter. By default all objects are
+// returned.
+func (res *Resource) PageSize(n int) *Resource {
+	res.mx.Lock()
+	defer res.mx.Unlock()
+	res.limit = n
+	return res
+}
+
+// Seed adds objects to the resource. The objects must marshal to JSON
+// objects, the ones without ID field get generated integer ID. Calls
+// t.Fatal() on error.
+func (res *Resource) Seed(objs ...interface{}) *Resource {
+	res.t.Helper()
+	res.mx.Lock()
+	defer res.mx.Unlock()
+	for _, v := range objs {
+		var obj map[string]interface{}
+		FromJSON(res.t, ToJSON(res.t, v), &obj)
+		if _, err := res.create(obj); err != nil {
+			res.t.Fatal(err)
+			return res
+		}
+	}
+	return res
+}
+
+// Len returns number of stored objects.
+func (res *Resource) Len() int {
+	res.mx.Lock()
+	defer res.mx.Unlock()
+	return len(res.ids)
+}
+
+// Items returns copies of stored objects in insertion order.
+func (res *Resource) Items() []map[string]interface{} {
+	res.mx.Lock()
+	defer res.mx.Unlock()
+	objs := make([]map[string]interface{}, 0, len(res.ids))
+	for _, id := range res.ids {
+		objs = append(objs, resCopy(res.objs[id]))
+	}
+	return objs
+}
+
+// Get returns copy of stored object with ID or nil if it does not exist.
+func (res *Resource) Get(id string) map[string]interface{} {
+	res.mx.Lock()
+	defer res.mx.Unlock()
+	if obj, ok := res.objs[id]; ok {
+		return resCopy(obj)
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (res *Resource) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rec := httptest.NewRecorder()
+	res.serve(rec, req)
+	for key, values := range rec.Header() {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(rec.Code)
+	_, _ = w.Write(rec.Body.Bytes())
+}
+
+// serve builds response to the request. The response is written to the
+// client by ServeHTTP after the lock is released.
+func (res *Resource) serve(w http.ResponseWriter, req *http.Request) {
+	id, _ := res.id(req.URL.Path)
+
+	res.mx.Lock()
+	defer res.mx.Unlock()
+
+	if id == "" {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+			res.list(w, req)
+		case http.MethodPost:
+			res.post(w, req)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		res.get(w, req, id)
+	case http.MethodPut:
+		res.put(w, req, id)
+	case http.MethodPatch:
+		res.patch(w, req, id)
+	case http.MethodDelete:
+		res.delete(w, req, id)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, PATCH, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list writes page of objects.
+func (res *Resource) list(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	limit, err := resInt(q.Get("limit"), res.limit)
+	if err != nil {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	offset, err := resInt(q.Get("offset"), 0)
+	if err != nil {
+		http.Error(w, "invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	total := len(res.ids)
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+		next := *req.URL
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(end))
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	if offset > end {
+		offset = end
+	}
+
+	objs := make([]map[string]interface{}, 0, end-offset)
+	for _, id := range res.ids[offset:end] {
+		objs = append(objs, res.objs[id])
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	resWrite(w, http.StatusOK, objs)
+}
+
+// post creates object.
+func (res *Resource) post(w http.ResponseWriter, req *http.Request) {
+	obj, ok := resRead(w, req)
+	if !ok {
+		return
+	}
+	id, err := res.create(obj)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Location", res.path+"/"+url.PathEscape(id))
+	w.Header().Set("ETag", resETag(obj))
+	resWrite(w, http.StatusCreated, obj)
+}
+
+// get writes object with ID.
+func (res *Resource) get(w http.ResponseWriter, req *http.Request, id string) {
+	obj, ok := res.objs[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	etag := resETag(obj)
+	w.Header().Set("ETag", etag)
+	if resETagMatch(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	resWrite(w, http.StatusOK, obj)
+}
+
+// put replaces or creates object with ID.
+func (res *Resource) put(w http.ResponseWriter, req *http.Request, id string) {
+	old, exists := res.objs[id]
+	if !res.precondition(w, req, old, exists) {
+		return
+	}
+	obj, ok := resRead(w, req)
+	if !ok {
+		return
+	}
+
+	status := http.StatusOK
+	if exists {
+		obj[res.idf] = old[res.idf]
+	} else {
+		obj[res.idf] = id
+		res.ids = append(res.ids, id)
+		status = http.StatusCreated
+	}
+	res.objs[id] = obj
+	w.Header().Set("ETag", resETag(obj))
+	resWrite(w, status, obj)
+}
+
+// patch updates object with ID using JSON merge patch.
+func (res *Resource) patch(w http.ResponseWriter, req *http.Request, id string) {
+	obj, exists := res.objs[id]
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !res.precondition(w, req, obj, exists) {
+		return
+	}
+	patch, ok := resRead(w, req)
+	if !ok {
+		return
+	}
+
+	obj = resMerge(resCopy(obj), patch)
+	obj[res.idf] = res.objs[id][res.idf]
+	res.objs[id] = obj
+	w.Header().Set("ETag", resETag(obj))
+	resWrite(w, http.StatusOK, obj)
+}
+
+// delete deletes object with ID.
+func (res *Resource) delete(w http.ResponseWriter, req *http.Request, id string) {
+	obj, exists := res.objs[id]
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !res.precondition(w, req, obj, exists) {
+		return
+	}
+	delete(res.objs, id)
+	for i, v := range res.ids {
+		if v == id {
+			res.ids = append(res.ids[:i], res.ids[i+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// precondition checks If-Match header against the object ETag. Writes
+// status 412 and returns false if the precondition fails.
+func (res *Resource) precondition(w http.ResponseWriter, req *http.Request, obj map[string]interface{}, exists bool) bool {
+	im := req.Header.Get("If-Match")
+	if im == "" || (exists && resETagMatch(im, resETag(obj))) {
+		return true
+	}
+	w.WriteHeader(http.StatusPreconditionFailed)
+	return false
+}
+
+// create stores new object generating ID if it's missing. Returns object
+// ID or error if the object with the same ID already exists.
+//
+// Must be called with the lock held.
+func (res *Resource) create(obj map[string]interface{}) (string, error) {
+	if _, ok := obj[res.idf]; !ok {
+		for {
+			res.seq++
+			if _, ok := res.objs[strconv.Itoa(res.seq)]; !ok {
+				break
+			}
+		}
+		obj[res.idf] = res.seq
+	}
+	id := resID(obj[res.idf])
+	if _, ok := res.objs[id]; ok {
+		return "", fmt.Errorf("resource %s with ID %s already exists", res.path, id)
+	}
+	res.ids = append(res.ids, id)
+	res.objs[id] = obj
+	return id, nil
+}
+
+// id returns object ID from the request path. Returns empty string for
+// the collection path. Returns false if the path does not belong to the
+// resource.
+func (res *Resource) id(pth string) (string, bool) {
+	if pth == res.path || pth == res.path+"/" {
+		return "", true
+	}
+	id := strings.TrimPrefix(pth, res.path+"/")
+	if id == pth || id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// resID returns object ID value as a string.
+func resID(v interface{}) string {
+	switch id := v.(type) {
+	case string:
+		return id
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// resInt parses integer query parameter value returning def for empty s.
+func resInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return n, nil
+}
+
+// resRead reads JSON object from the request body. Writes status 400 and
+// returns false if the body is not a JSON object.
+func resRead(w http.ResponseWriter, req *http.Request) (map[string]interface{}, bool) {
+	var obj map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&obj); err != nil || obj == nil {
+		http.Error(w, "expected JSON object", http.StatusBadRequest)
+		return nil, false
+	}
+	return obj, true
+}
+
+// resWrite writes v as JSON response with status.
+func resWrite(w http.ResponseWriter, status int, v interface{}) {
+	data, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// resETag returns strong ETag of the object.
+func resETag(obj map[string]interface{}) string {
+	data, _ := json.Marshal(obj)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// resETagMatch returns true if If-Match or If-None-Match header value hdr
+// matches etag.
+func resETagMatch(hdr, etag string) bool {
+	for _, v := range strings.Split(hdr, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// resMerge applies JSON merge patch (RFC 7386) to the object.
+func resMerge(obj, patch map[string]interface{}) map[string]interface{} {
+	for k, v := range patch {
+		if v == nil {
+			delete(obj, k)
+			continue
+		}
+		pm, ok := v.(map[string]interface{})
+		if !ok {
+			obj[k] = v
+			continue
+		}
+		om, ok := obj[k].(map[string]interface{})
+		if !ok {
+			om = make(map[string]interface{})
+		}
+		obj[k] = resMerge(om, pm)
+	}
+	return obj
+}
+
+// resCopy returns deep copy of the object.
+func resCopy(obj map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(obj)
+	var c map[string]interface{}
+	_ = json.Unmarshal(data, &c)
+	return c
+}
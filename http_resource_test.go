@@ -0,0 +1,218 @@
+package testkit_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+// doRequest sends request with method and body to URL u. Sets If-Match
+// header when etag is not empty.
+func doRequest(t *testing.T, method, u, body, etag string) *http.Response {
+	req, err := http.NewRequest(method, u, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return rsp
+}
+
+func Test_HTTPServer_Resource_CRUD(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	res := srv.Resource("/users", "id")
+	u := srv.URL() + "/users"
+
+	// --- When ---
+	rsp0 := doRequest(t, http.MethodPost, u, `{"name":"Bob"}`, "")
+	rsp1 := doRequest(t, http.MethodPost, u, `{"id":1,"name":"Tom"}`, "")
+	rsp2 := doRequest(t, http.MethodPut, u+"/abc", `{"name":"Ann"}`, "")
+	rsp3 := doRequest(t, http.MethodPatch, u+"/1", `{"age":30}`, "")
+	rsp4 := doRequest(t, http.MethodGet, u+"/1", "", "")
+	rsp5 := doRequest(t, http.MethodDelete, u+"/abc", "", "")
+	rsp6 := doRequest(t, http.MethodGet, u+"/abc", "", "")
+	rsp7 := doRequest(t, http.MethodGet, u, "", "")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	assert.Exactly(t, http.StatusCreated, rsp0.StatusCode)
+	assert.Exactly(t, "/users/1", rsp0.Header.Get("Location"))
+	assert.NotEmpty(t, rsp0.Header.Get("ETag"))
+	assert.JSONEq(t, `{"id":1,"name":"Bob"}`, string(getResponseBody(t, rsp0)))
+
+	assert.Exactly(t, http.StatusConflict, rsp1.StatusCode)
+
+	assert.Exactly(t, http.StatusCreated, rsp2.StatusCode)
+	assert.JSONEq(t, `{"id":"abc","name":"Ann"}`, string(getResponseBody(t, rsp2)))
+
+	assert.Exactly(t, http.StatusOK, rsp3.StatusCode)
+	assert.JSONEq(t, `{"id":1,"name":"Bob","age":30}`, string(getResponseBody(t, rsp3)))
+
+	assert.Exactly(t, http.StatusOK, rsp4.StatusCode)
+	assert.JSONEq(t, `{"id":1,"name":"Bob","age":30}`, string(getResponseBody(t, rsp4)))
+
+	assert.Exactly(t, http.StatusNoContent, rsp5.StatusCode)
+	assert.Exactly(t, http.StatusNotFound, rsp6.StatusCode)
+
+	assert.Exactly(t, http.StatusOK, rsp7.StatusCode)
+	assert.Exactly(t, "1", rsp7.Header.Get("X-Total-Count"))
+	assert.JSONEq(t, `[{"id":1,"name":"Bob","age":30}]`, string(getResponseBody(t, rsp7)))
+
+	assert.Exactly(t, 1, res.Len())
+	exp := map[string]interface{}{"id": 1.0, "name": "Bob", "age": 30.0}
+	assert.Exactly(t, exp, res.Get("1"))
+	assert.Exactly(t, []map[string]interface{}{exp}, res.Items())
+	assert.Nil(t, res.Get("abc"))
+	assert.Exactly(t, 8, srv.ReqCount())
+}
+
+func Test_HTTPServer_Resource_ETag(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Resource("/users", "id").Seed(map[string]interface{}{"id": "a", "name": "Bob"})
+	u := srv.URL() + "/users/a"
+
+	rsp := doRequest(t, http.MethodGet, u, "", "")
+	etag := rsp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+
+	// --- When ---
+	rsp0, err0 := http.DefaultClient.Do(req)
+	rsp1 := doRequest(t, http.MethodPut, u, `{"name":"Tom"}`, `"stale"`)
+	rsp2 := doRequest(t, http.MethodPut, u, `{"name":"Tom"}`, etag)
+	rsp3 := doRequest(t, http.MethodDelete, u, "", etag)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusNotModified, rsp0.StatusCode)
+	assert.Exactly(t, http.StatusPreconditionFailed, rsp1.StatusCode)
+	assert.Exactly(t, http.StatusOK, rsp2.StatusCode)
+	assert.NotEqual(t, etag, rsp2.Header.Get("ETag"))
+	assert.Exactly(t, http.StatusPreconditionFailed, rsp3.StatusCode)
+}
+
+func Test_HTTPServer_Resource_pagination(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Resource("/items", "id").PageSize(2).Seed(
+		map[string]interface{}{"v": "a"},
+		map[string]interface{}{"v": "b"},
+		map[string]interface{}{"v": "c"},
+	)
+
+	// --- When ---
+	rsp0 := doRequest(t, http.MethodGet, srv.URL()+"/items", "", "")
+	rsp1 := doRequest(t, http.MethodGet, srv.URL()+"/items?limit=2&offset=2", "", "")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, "3", rsp0.Header.Get("X-Total-Count"))
+	assert.Exactly(t, `</items?limit=2&offset=2>; rel="next"`, rsp0.Header.Get("Link"))
+	assert.JSONEq(t, `[{"id":1,"v":"a"},{"id":2,"v":"b"}]`, string(getResponseBody(t, rsp0)))
+
+	assert.Exactly(t, http.StatusOK, rsp1.StatusCode)
+	assert.Exactly(t, "", rsp1.Header.Get("Link"))
+	assert.JSONEq(t, `[{"id":3,"v":"c"}]`, string(getResponseBody(t, rsp1)))
+}
+
+func Test_HTTPServer_Resource_errors(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Resource("/users", "id")
+	u := srv.URL() + "/users"
+
+	// --- When ---
+	rsp0 := doRequest(t, http.MethodPost, u, `[1]`, "")
+	rsp1 := doRequest(t, http.MethodPatch, u+"/1", `{}`, "")
+	rsp2 := doRequest(t, http.MethodDelete, u, "", "")
+	rsp3 := doRequest(t, http.MethodGet, u+"?limit=x", "", "")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, http.StatusBadRequest, rsp0.StatusCode)
+	assert.Exactly(t, http.StatusNotFound, rsp1.StatusCode)
+	assert.Exactly(t, http.StatusMethodNotAllowed, rsp2.StatusCode)
+	assert.Exactly(t, "GET, HEAD, POST", rsp2.Header.Get("Allow"))
+	assert.Exactly(t, http.StatusBadRequest, rsp3.StatusCode)
+}
+
+// blockingWriter is http.ResponseWriter blocking writes until released.
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{} // Closed when the first write starts.
+	release chan struct{} // Close to unblock writes.
+	once    sync.Once
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	bw.once.Do(func() { close(bw.writing) })
+	<-bw.release
+	return bw.ResponseRecorder.Write(p)
+}
+
+func Test_HTTPServer_Resource_writesWithoutLock(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	res := srv.Resource("/users", "id").Seed(map[string]interface{}{"id": 1})
+
+	w := &blockingWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		writing:          make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	}()
+	<-w.writing
+
+	// --- When ---
+	got := res.Len()
+
+	// --- Then ---
+	close(w.release)
+	<-done
+	mck.AssertExpectations(t)
+	assert.Exactly(t, 1, got)
+	assert.Exactly(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":1}`, w.Body.String())
+}
@@ -0,0 +1,367 @@
+package testkit
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Connection level faults simulated by the Response.
+const (
+	faultNone  = iota // No fault.
+	faultClose        // Close connection without a response.
+	faultReset        // Reset connection without a response.
+	faultStall        // Never respond.
+)
+
+// Response represents a response returned by HTTPServer.
+//
+// Response is a builder, every method configures the response and returns
+// it so the calls can be chained.
+//
+//	srv.Response().
+//		Status(http.StatusCreated).
+//		Header("Location", "/users/1").
+//		JSON(user)
+//
+// The response should be configured before the request it answers is made.
+type Response struct {
+	status      int             // HTTP status code.
+	header      http.Header     // Response headers.
+	cookies     []*http.Cookie  // Response cookies.
+	body        []byte          // Response body.
+	trailer     http.Header     // Response trailers.
+	delay       time.Duration   // Delay before writing headers.
+	chunk       int             // Body chunk size, when zero body written at once.
+	pause       time.Duration   // Delay between body chunks.
+	partial     int             // Number of body bytes to send, -1 for all.
+	fault       int             // Connection level fault.
+	chunks      [][]byte        // Body chunks.
+	stream      <-chan []byte   // Streamed body chunks.
+	events      <-chan SSEEvent // Streamed Server-Sent Events.
+	poll        <-chan []byte   // Long poll body.
+	pollTimeout time.Duration   // Long poll timeout.
+	expect      []Matcher       // Expectations for the answered request.
+	mx          *sync.Mutex     // Guards the response fields.
+	t           T               // Test state manager.
+}
+
+// newResponse returns new response with status 200 guarded by mx.
+func newResponse(t T, mx *sync.Mutex) *Response {
+	return &Response{
+		status:  http.StatusOK,
+		header:  make(http.Header),
+		trailer: make(http.Header),
+		partial: -1,
+		mx:      mx,
+		t:       t,
+	}
+}
+
+// Response adds new response with status 200 to the list of responses to
+// return and returns it, so it can be configured. See Rsp() for details
+// on the order responses are returned.
+func (tst *HTTPServer) Response() *Response {
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
+	rsp := newResponse(tst.t, &tst.mx)
+	tst.responses = append(tst.responses, rsp)
+	tst.responseCnt++
+	return rsp
+}
+
+// Response adds new response with status 200 to the list of route
+// responses and returns it, so it can be configured.
+func (rt *Route) Response() *Response {
+	rt.tst.mx.Lock()
+	defer rt.tst.mx.Unlock()
+	rsp := newResponse(rt.tst.t, &rt.tst.mx)
+	rt.responses = append(rt.responses, rsp)
+	rt.tst.responseCnt++
+	return rsp
+}
+
+// Status sets response HTTP status code.
+func (rsp *Response) Status(status int) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.status = status
+	return rsp
+}
+
+// Header adds response header value to the key.
+func (rsp *Response) Header(key, value string) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.header.Add(key, value)
+	return rsp
+}
+
+// ContentType sets response Content-Type header.
+func (rsp *Response) ContentType(ct string) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.header.Set("Content-Type", ct)
+	return rsp
+}
+
+// Cookie adds Set-Cookie header to the response.
+func (rsp *Response) Cookie(c *http.Cookie) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.cookies = append(rsp.cookies, c)
+	return rsp
+}
+
+// Trailer adds response trailer value to the key. The trailer is
+// announced in the Trailer header and sent after the response body.
+func (rsp *Response) Trailer(key, value string) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.trailer.Add(key, value)
+	return rsp
+}
+
+// Body sets response body. The body can be set to nil in which case no
+// response body will send.
+func (rsp *Response) Body(body []byte) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.body = body
+	return rsp
+}
+
+// JSON sets response body to v marshalled with ToJSON() and sets
+// Content-Type header to "application/json". Calls t.Fatal() on error.
+func (rsp *Response) JSON(v interface{}) *Response {
+	rsp.t.Helper()
+	data := ToJSON(rsp.t, v)
+	return rsp.ContentType("application/json").Body(data)
+}
+
+// XML sets response body to v marshalled with ToXML() and sets
+// Content-Type header to "application/xml". Calls t.Fatal() on error.
+func (rsp *Response) XML(v interface{}) *Response {
+	rsp.t.Helper()
+	data := ToXML(rsp.t, v)
+	return rsp.ContentType("application/xml").Body(data)
+}
+
+// Expect adds expectations the request answered with the response must
+// meet. The expectations are verified during test cleanup, requests not
+// meeting them are reported with t.Errorf() showing every expected and
+// received value and a diff of the bodies.
+func (rsp *Response) Expect(matchers ...Matcher) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.expect = append(rsp.expect, matchers...)
+	return rsp
+}
+
+// Delay sets the time to wait before the response headers are written.
+func (rsp *Response) Delay(d time.Duration) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.delay = d
+	return rsp
+}
+
+// BodyDelay makes the response body to be written in chunks of size bytes
+// with delay d between them. Every chunk is flushed to the client.
+func (rsp *Response) BodyDelay(size int, d time.Duration) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.chunk = size
+	rsp.pause = d
+	return rsp
+}
+
+// PartialBody makes the response to declare Content-Length of the whole
+// body but send only the first n bytes of it before the connection
+// is closed.
+func (rsp *Response) PartialBody(n int) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.partial = n
+	return rsp
+}
+
+// CloseConn makes the server close the connection without sending
+// the response.
+func (rsp *Response) CloseConn() *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.fault = faultClose
+	return rsp
+}
+
+// ResetConn makes the server reset (TCP RST) the connection without sending
+// the response. TLS connections are reset without the TLS close alert,
+// connections on Unix sockets are closed.
+func (rsp *Response) ResetConn() *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.fault = faultReset
+	return rsp
+}
+
+// Stall makes the server never respond. The request hangs until the client
+// gives up (for example its deadline fires) or the server is closed.
+func (rsp *Response) Stall() *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.fault = faultStall
+	return rsp
+}
+
+// clone returns copy of the response.
+//
+// Must be called with the lock held.
+func (rsp *Response) clone() *Response {
+	c := *rsp
+	c.header = rsp.header.Clone()
+	c.trailer = rsp.trailer.Clone()
+	c.cookies = append([]*http.Cookie{}, rsp.cookies...)
+	c.chunks = append([][]byte{}, rsp.chunks...)
+	c.expect = append([]Matcher{}, rsp.expect...)
+	return &c
+}
+
+// write writes the response to w simulating configured delays and faults.
+// Delays and stalls are interrupted when request is canceled or done
+// channel is closed, in which case the connection is closed without
+// sending the response. Calls t.Error() on error.
+func (rsp *Response) write(w http.ResponseWriter, req *http.Request, done <-chan struct{}) {
+	if !wait(rsp.delay, req, done) {
+		rsp.abort(w, req, false)
+		return
+	}
+
+	switch rsp.fault {
+	case faultStall:
+		select {
+		case <-req.Context().Done():
+		case <-done:
+		}
+		rsp.abort(w, req, false)
+		return
+	case faultClose, faultReset:
+		rsp.abort(w, req, rsp.fault == faultReset)
+		return
+	}
+
+	if rsp.poll != nil && !rsp.awaitPoll(req, done) {
+		rsp.abort(w, req, false)
+		return
+	}
+
+	hdr := w.Header()
+	for key, values := range rsp.header {
+		hdr[key] = append(hdr[key], values...)
+	}
+	for _, c := range rsp.cookies {
+		http.SetCookie(w, c)
+	}
+	for key := range rsp.trailer {
+		hdr.Add("Trailer", key)
+	}
+
+	body := rsp.body
+	if rsp.partial >= 0 && rsp.partial < len(body) {
+		hdr.Set("Content-Length", strconv.Itoa(len(body)))
+		body = body[:rsp.partial]
+	}
+
+	w.WriteHeader(rsp.status)
+	if rsp.streaming() {
+		if !rsp.writeStream(w, req, done) {
+			return
+		}
+	} else if !rsp.writeBody(w, body, req, done) {
+		return
+	}
+	if rsp.partial >= 0 && rsp.partial < len(rsp.body) {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		rsp.abort(w, req, false)
+		return
+	}
+
+	for key, values := range rsp.trailer {
+		hdr[key] = append(hdr[key], values...)
+	}
+}
+
+// writeBody writes body to w in configured chunks. Returns false if writing
+// was interrupted or failed. Calls t.Error() on error.
+func (rsp *Response) writeBody(w http.ResponseWriter, body []byte, req *http.Request, done <-chan struct{}) bool {
+	if body == nil {
+		return true
+	}
+	size := rsp.chunk
+	if size <= 0 {
+		size = len(body)
+	}
+	for off := 0; off < len(body); off += size {
+		if off > 0 && !wait(rsp.pause, req, done) {
+			return false
+		}
+		end := off + size
+		if end > len(body) {
+			end = len(body)
+		}
+		if _, err := w.Write(body[off:end]); err != nil {
+			rsp.t.Error(err)
+			return false
+		}
+		if f, ok := w.(http.Flusher); ok && rsp.chunk > 0 {
+			f.Flush()
+		}
+	}
+	return true
+}
+
+// abort closes the client connection. When reset is true the connection
+// is closed with TCP RST, for TLS connections the underlying TCP connection
+// is reset without sending TLS close alert. If the connection cannot be
+// hijacked the handler is aborted with http.ErrAbortHandler.
+func (rsp *Response) abort(w http.ResponseWriter, req *http.Request, reset bool) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if reset {
+		if raw, ok := req.Context().Value(rawConnKey{}).(net.Conn); ok {
+			conn = raw
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			_ = tcp.SetLinger(0)
+		}
+	}
+	_ = conn.Close()
+}
+
+// wait waits for duration d. Returns false if request was canceled or done
+// channel was closed before d elapsed.
+func wait(d time.Duration, req *http.Request, done <-chan struct{}) bool {
+	if d <= 0 {
+		return true
+	}
+	tmr := time.NewTimer(d)
+	defer tmr.Stop()
+	select {
+	case <-tmr.C:
+		return true
+	case <-req.Context().Done():
+		return false
+	case <-done:
+		return false
+	}
+}
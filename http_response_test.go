@@ -0,0 +1,232 @@
+package testkit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_Response_builder(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().
+		Status(http.StatusCreated).
+		Header("Location", "/users/1").
+		Header("ETag", `"abc"`).
+		Cookie(&http.Cookie{Name: "session", Value: "s1"}).
+		Trailer("X-Checksum", "123").
+		JSON(map[string]string{"id": "1"})
+
+	// --- When ---
+	rsp, err := http.Post(srv.URL()+"/users", "", nil)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusCreated, rsp.StatusCode)
+	assert.Exactly(t, "application/json", rsp.Header.Get("Content-Type"))
+	assert.Exactly(t, "/users/1", rsp.Header.Get("Location"))
+	assert.Exactly(t, `"abc"`, rsp.Header.Get("ETag"))
+	require.Len(t, rsp.Cookies(), 1)
+	assert.Exactly(t, "session", rsp.Cookies()[0].Name)
+	assert.Exactly(t, "s1", rsp.Cookies()[0].Value)
+	assert.Exactly(t, `{"id":"1"}`, string(getResponseBody(t, rsp)))
+	assert.Exactly(t, "123", rsp.Trailer.Get("X-Checksum"))
+}
+
+func Test_Response_XML(t *testing.T) {
+	// --- Given ---
+	type user struct {
+		ID string `xml:"id"`
+	}
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Route(kit.MatchPath("/user")).
+		Response().
+		Header("Retry-After", "120").
+		XML(user{ID: "1"})
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL() + "/user")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, "application/xml", rsp.Header.Get("Content-Type"))
+	assert.Exactly(t, "120", rsp.Header.Get("Retry-After"))
+	exp := "<user><id>1</id></user>"
+	assert.Exactly(t, exp, string(getResponseBody(t, rsp)))
+}
+
+func Test_Response_Delay(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().Delay(100 * time.Millisecond).Body([]byte("slow"))
+	srv.Response().Delay(time.Second)
+
+	cli := &http.Client{Timeout: 50 * time.Millisecond}
+
+	// --- When ---
+	start := time.Now()
+	rsp, err0 := http.Get(srv.URL())
+	elapsed := time.Since(start)
+	_, err1 := cli.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.True(t, elapsed >= 100*time.Millisecond)
+	assert.Exactly(t, "slow", string(getResponseBody(t, rsp)))
+	assert.Error(t, err1)
+}
+
+func Test_Response_BodyDelay(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().BodyDelay(2, 30*time.Millisecond).Body([]byte("abcdef"))
+
+	// --- When ---
+	start := time.Now()
+	rsp, err := http.Get(srv.URL())
+	require.NoError(t, err)
+	body := getResponseBody(t, rsp)
+	elapsed := time.Since(start)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, "abcdef", string(body))
+	assert.True(t, elapsed >= 60*time.Millisecond)
+}
+
+func Test_Response_Stall(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().Stall()
+
+	ctx, cxl := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cxl()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL(), nil)
+	require.NoError(t, err)
+
+	// --- When ---
+	_, err = http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Exactly(t, 1, srv.ReqCount())
+}
+
+func Test_Response_Stall_closeServer(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().Stall()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := http.Get(srv.URL())
+		errs <- err
+	}()
+	kit.Wait(time.Second, func() bool { return srv.ReqCount() == 1 })
+
+	// --- When ---
+	require.NoError(t, srv.Close())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Error(t, <-errs)
+}
+
+func Test_Response_connectionFaults(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		opts  []kit.HTTPServerOption
+		fn    func(rsp *kit.Response)
+		reset bool
+	}{
+		{"close", nil, func(rsp *kit.Response) { rsp.CloseConn() }, false},
+		{"reset", nil, func(rsp *kit.Response) { rsp.ResetConn() }, true},
+		{"tls close", []kit.HTTPServerOption{kit.WithTLS()}, func(rsp *kit.Response) { rsp.CloseConn() }, false},
+		{"tls reset", []kit.HTTPServerOption{kit.WithTLS()}, func(rsp *kit.Response) { rsp.ResetConn() }, true},
+		{"mtls reset", []kit.HTTPServerOption{kit.WithMTLS()}, func(rsp *kit.Response) { rsp.ResetConn() }, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			mck := &kit.TMock{}
+			mck.On("Cleanup", mock.Anything)
+			mck.On("Helper")
+
+			srv := kit.NewHTTPServer(mck, tc.opts...)
+			tc.fn(srv.Response())
+
+			// --- When ---
+			_, err := srv.Client().Get(srv.URL())
+
+			// --- Then ---
+			mck.AssertExpectations(t)
+			require.Error(t, err)
+			assert.Exactly(t, tc.reset, errors.Is(err, syscall.ECONNRESET))
+			assert.Exactly(t, 1, srv.ReqCount())
+		})
+	}
+}
+
+func Test_Response_PartialBody(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().PartialBody(3).Body([]byte("abcdef"))
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, int64(6), rsp.ContentLength)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Exactly(t, "abc", string(body))
+}
@@ -0,0 +1,160 @@
+package testkit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Route represents a set of matchers and responses registered with
+// HTTPServer. Requests matching all the matchers are answered with route
+// responses in the order they were added. When route has a handler it
+// answers matching requests after all the responses were used.
+type Route struct {
+	matchers  []Matcher    // Request matchers.
+	responses []*Response  // Responses to return.
+	handler   http.Handler // Handler answering requests, may be nil.
+	calls     int          // Number of requests served by the route.
+	tst       *HTTPServer
+}
+
+// Route registers new route with matchers. The routes are checked in the
+// order they were registered, the first route matching the request which
+// still has responses to give is used. When no route matches the request
+// the server falls back to responses added with HTTPServer.Rsp().
+func (tst *HTTPServer) Route(matchers ...Matcher) *Route {
+	rt := &Route{
+		matchers: matchers,
+		tst:      tst,
+	}
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
+	tst.routes = append(tst.routes, rt)
+	return rt
+}
+
+// Rsp adds response with status and body to the list of route responses.
+// The rsp can be set to nil in which case no response body will send.
+func (rt *Route) Rsp(status int, rsp []byte) *Route {
+	rt.Response().Status(status).Body(rsp)
+	return rt
+}
+
+// Handler sets handler answering requests matching the route after all
+// the route responses were used. Requests answered by the handler are not
+// counted as expected requests by the server.
+func (rt *Route) Handler(h http.Handler) *Route {
+	rt.tst.mx.Lock()
+	defer rt.tst.mx.Unlock()
+	rt.handler = h
+	return rt
+}
+
+// Calls returns number of requests served by the route.
+func (rt *Route) Calls() int {
+	rt.tst.mx.Lock()
+	defer rt.tst.mx.Unlock()
+	return rt.calls
+}
+
+// String returns route description.
+func (rt *Route) String() string {
+	ms := make([]string, 0, len(rt.matchers))
+	for _, m := range rt.matchers {
+		ms = append(ms, m.String())
+	}
+	return strings.Join(ms, ", ")
+}
+
+// routeMatch represents results of route matchers for a request.
+type routeMatch struct {
+	rt  *Route // The route.
+	res []bool // Results of the route matchers.
+}
+
+// score returns number of route matchers matching the request.
+func (rm routeMatch) score() int {
+	var cnt int
+	for _, ok := range rm.res {
+		if ok {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// explain returns description of which route matchers do and do not
+// match the request.
+//
+// Must be called with the lock held.
+func (rm routeMatch) explain() string {
+	rt := rm.rt
+	ms := make([]string, 0, len(rt.matchers))
+	for i, m := range rt.matchers {
+		res := "no match"
+		if rm.res[i] {
+			res = "match"
+		}
+		ms = append(ms, fmt.Sprintf("%s (%s)", m, res))
+	}
+	if len(rt.responses) == 0 && rt.handler == nil {
+		ms = append(ms, "no more responses to give")
+	}
+	return strings.Join(ms, ", ")
+}
+
+// match runs matchers of the routes against the request. The matchers
+// may call HTTPServer methods so it must be called without the lock held.
+func match(routes []*Route, req *http.Request, body []byte) []routeMatch {
+	rms := make([]routeMatch, 0, len(routes))
+	for _, rt := range routes {
+		res := make([]bool, len(rt.matchers))
+		for i, m := range rt.matchers {
+			res[i] = m.Match(req, body)
+		}
+		rms = append(rms, routeMatch{rt: rt, res: res})
+	}
+	return rms
+}
+
+// route returns response or handler from the first matched route. Returns
+// false if no route matches the request or matching routes have no more
+// responses to give and no handlers.
+//
+// Must be called with the lock held.
+func (tst *HTTPServer) route(rms []routeMatch) (*Response, http.Handler, bool) {
+	for _, rm := range rms {
+		rt := rm.rt
+		if len(rt.responses) == 0 && rt.handler == nil {
+			continue
+		}
+		if rm.score() != len(rt.matchers) {
+			continue
+		}
+		rt.calls++
+		if len(rt.responses) == 0 {
+			return nil, rt.handler, true
+		}
+		var rsp *Response
+		rsp, rt.responses = rt.responses[0], rt.responses[1:]
+		return rsp, nil, true
+	}
+	return nil, nil, false
+}
+
+// closest returns description of the matched route with the most matchers
+// matching the request. Returns empty string if there are no routes.
+//
+// Must be called with the lock held.
+func (tst *HTTPServer) closest(rms []routeMatch) string {
+	var best *routeMatch
+	for i := range rms {
+		if best == nil || rms[i].score() > best.score() {
+			best = &rms[i]
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.explain()
+}
@@ -2,73 +2,128 @@ package testkit
 
 import (
 	"bytes"
-	"io"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
-// response represents a response returned by HTTPServer.
-type response struct {
-	status int    // HTTP status code.
-	body   []byte // Response body.
+// record represents request recorded by HTTPServer.
+type record struct {
+	req  *http.Request     // Received request without the body.
+	body []byte            // Received request body.
+	peer *x509.Certificate // Client certificate if presented.
+	hnd  bool              // Request answered by a handler.
+	thr  bool              // Request throttled by the rate limiter.
+	den  error             // Reason request was rejected as not authenticated.
+	rsp  *Response         // Response the request was answered with.
+	at   time.Time         // The time request was received.
+	out  *served           // Served response, set after it's written.
+	fin  chan struct{}     // Closed when out is set.
+}
+
+// rejected returns true if the request was rejected by the rate limiter
+// or as not authenticated.
+func (rec record) rejected() bool { return rec.thr || rec.den != nil }
+
+// request returns clone of the recorded request with its body.
+func (rec record) request() *http.Request {
+	c := rec.req.Clone(context.Background())
+	c.Body = ioutil.NopCloser(bytes.NewReader(rec.body))
+	return c
 }
 
 // HTTPServer represents very simple HTTP server recording all the requests it
-// receives, responding with responses added with Rsp() method.
+// receives, responding with responses added with Rsp() method or registered
+// with routes using Route() method.
 //
 // The server instance provides methods to analyse the received requests.
+// All the methods are safe for concurrent use, the server may be serving
+// requests while the test inspects the ones already received.
 type HTTPServer struct {
-	requests    []*http.Request  // Received requests.
-	srv         *httptest.Server // The test server.
-	host        string           // Test server host:port.
-	scheme      string           // Test server scheme.
-	responseCnt int              // Number of added responses.
-	responses   []response       // Responses to return.
-	t           T                // Test state manager.
-}
-
-// NewHTTPServer returns new instance of HTTPServer and registers call to Close
-// in test cleanup. The server will fail the test if during cleanup the number
-// of expected responses does not match the number of seen requests.
-func NewHTTPServer(t T) *HTTPServer {
+	srv         *httptest.Server    // The test server.
+	host        string              // Test server host:port.
+	scheme      string              // Test server scheme.
+	t           T                   // Test state manager.
+	tls         bool                // Serve HTTPS.
+	mtls        bool                // Require client certificates.
+	http2       bool                // Enable HTTP/2.
+	ca          *CA                 // Certificate authority in TLS mode.
+	fallback    http.Handler        // Handler for requests without responses.
+	cassette    *cassette           // Cassette in record and replay mode.
+	openapi     *openAPI            // OpenAPI document requests are validated against.
+	limiter     limiter             // Rate limiting policy, may be nil.
+	auth        []AuthChecker       // Request authentication checkers.
+	public      string              // Path excluded from authentication.
+	decompress  bool                // Decompress recorded request bodies.
+	compress    bool                // Compress responses.
+	sock        string              // Unix socket path.
+	lis         net.Listener        // Custom listener.
+	redact      func(http.Header)   // Header redaction hook.
+	rspTimeout  time.Duration       // How long response accessors wait.
+	closers     []func()            // Called on cleanup after server stops.
+	done        chan struct{}       // Closed when server is closing.
+	closeOnce   sync.Once           // Makes sure done is closed only once.
+	mx          sync.Mutex          // Guards fields below.
+	requests    []record            // Received requests.
+	responseCnt int                 // Number of added responses.
+	responses   []*Response         // Responses to return.
+	routes      []*Route            // Registered routes.
+	graphql     map[string]*GraphQL // GraphQL endpoints by path.
+}
+
+// NewHTTPServer returns new instance of HTTPServer configured with options
+// and registers call to Close in test cleanup. The server will fail the test
+// if during cleanup the number of expected responses does not match the
+// number of seen requests.
+func NewHTTPServer(t T, opts ...HTTPServerOption) *HTTPServer {
 	t.Helper()
 	tst := &HTTPServer{
-		t: t,
+		t:          t,
+		rspTimeout: rspTimeout,
+		done:       make(chan struct{}),
 	}
 
 	// Cleanup after the test is done.
 	t.Cleanup(func() {
 		t.Helper()
-		if len(tst.requests) != tst.responseCnt {
-			t.Errorf(
-				"expected %d requests got %d",
-				tst.responseCnt,
-				len(tst.requests),
-			)
+		tst.stop()
+		tst.mx.Lock()
+		exp, got := tst.responseCnt, 0
+		for _, rec := range tst.requests {
+			if !rec.hnd && !rec.rejected() {
+				got++
+			}
+		}
+		tst.mx.Unlock()
+		if got != exp {
+			t.Errorf("expected %d requests got %d", exp, got)
+		}
+		tst.verify()
+		for _, fn := range tst.closers {
+			fn()
 		}
 		_ = tst.Close()
 	})
 
-	// Handler for all incoming requests.
-	handler := func(w http.ResponseWriter, req *http.Request) {
-		rsp := tst.next()
-		w.WriteHeader(rsp.status)
-
-		c := CloneHTTPRequest(t, req)
-		c.URL.Scheme = tst.scheme
-
-		tst.requests = append(tst.requests, c)
-		if rsp.body != nil {
-			if _, err := w.Write(rsp.body); err != nil {
-				t.Fatal(err)
-				return
-			}
-		}
+	tst.srv = httptest.NewUnstartedServer(http.HandlerFunc(tst.handle))
+	for _, opt := range opts {
+		opt(tst)
+	}
+	if tst.cassette != nil {
+		tst.cassette.redact = tst.redact
 	}
+	tst.start()
 
-	tst.srv = httptest.NewServer(http.HandlerFunc(handler))
 	u, err := url.Parse(tst.srv.URL)
 	if err != nil {
 		t.Fatal(err)
@@ -80,18 +135,159 @@ func NewHTTPServer(t T) *HTTPServer {
 	return tst
 }
 
+// start starts the server according to its configuration.
+func (tst *HTTPServer) start() {
+	tst.t.Helper()
+	tst.listen()
+	defer tst.dial()
+
+	if tst.tls {
+		tst.srv.EnableHTTP2 = tst.http2
+		tst.startTLS()
+		return
+	}
+
+	if tst.http2 {
+		tst.srv.Config.Handler = h2c.NewHandler(tst.srv.Config.Handler, &http2.Server{})
+	}
+	tst.srv.Start()
+	if tst.http2 {
+		dialer := tst.Dialer()
+		tst.srv.Client().Transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, nw, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer(ctx, nw, addr)
+			},
+		}
+	}
+}
+
+// handle is the handler for all incoming requests.
+func (tst *HTTPServer) handle(w http.ResponseWriter, req *http.Request) {
+	at := time.Now()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		tst.t.Error(err)
+		return
+	}
+	raw := body
+	if tst.decompress {
+		if body, err = decompress(req.Header.Get("Content-Encoding"), body); err != nil {
+			tst.t.Error(err)
+		}
+	}
+	scheme := tst.scheme
+	if s := req.Header.Get(interceptSchemeHeader); s == "http" || s == "https" {
+		scheme = s
+	}
+	req.Header.Del(interceptSchemeHeader)
+	c := req.Clone(context.Background())
+	c.Body = nil
+	c.URL.Host = req.Host
+	c.URL.Scheme = scheme
+	rec := record{
+		req:  c,
+		body: body,
+		at:   at,
+		out:  &served{},
+		fin:  make(chan struct{}),
+	}
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		rec.peer = req.TLS.PeerCertificates[0]
+	}
+	if tst.openapi != nil {
+		tst.openapi.validate(c, body)
+	}
+
+	tst.mx.Lock()
+	var rl *rateState
+	if tst.limiter != nil {
+		st := tst.limiter.take(at)
+		rl, rec.thr = &st, st.throttled
+	}
+	auth, public, routes := tst.auth, tst.public, tst.routes
+	tst.mx.Unlock()
+
+	// Checkers and matchers are user code, run them without the lock.
+	if !rec.thr && c.URL.Path != public {
+		rec.den = authenticate(auth, c, raw)
+	}
+	var rms []routeMatch
+	if !rec.rejected() {
+		rms = match(routes, c, body)
+	}
+
+	tst.mx.Lock()
+	var rsp *Response
+	var hnd http.Handler
+	var ok bool
+	if !rec.rejected() {
+		rsp, hnd, ok = tst.next(c, rms)
+		if !ok && tst.fallback != nil {
+			hnd, ok = tst.fallback, true
+		}
+		rec.rsp = rsp
+		rec.hnd = hnd != nil
+	}
+	tst.requests = append(tst.requests, rec)
+	if !ok && !rec.rejected() {
+		tst.unmatched(rec, rms)
+	}
+	tst.mx.Unlock()
+
+	var zw *compressWriter
+	if enc := acceptEncoding(req.Header.Get("Accept-Encoding")); tst.compress && enc != "" {
+		zw = &compressWriter{ResponseWriter: w, accept: enc, head: req.Method == http.MethodHead}
+		w = zw
+		defer zw.close()
+	}
+
+	cw := &captureWriter{ResponseWriter: w}
+	w = cw
+	defer func() {
+		out := cw.served()
+		out.took = time.Since(at)
+		if zw != nil && zw.enc != "" {
+			out.header.Set("Content-Encoding", zw.enc)
+			out.header.Add("Vary", "Accept-Encoding")
+		}
+		tst.mx.Lock()
+		*rec.out = out
+		tst.mx.Unlock()
+		close(rec.fin)
+	}()
+
+	if rl != nil {
+		rl.header(w.Header())
+	}
+	switch {
+	case rec.thr:
+		rl.reject(w)
+	case rec.den != nil:
+		tst.deny(w)
+	case rsp != nil:
+		rsp.write(w, req, tst.done)
+	case hnd != nil:
+		r := req.Clone(req.Context())
+		r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		hnd.ServeHTTP(w, r)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
 // Rsp adds response with status and body to the list of responses to return.
 //
 // Every time the server receives a request it returns predefined response. The
 // responses are returned to the order they were added. The rsp can be set to
-// nil in which case no response body will send. The t.Fatal() will be called
-// if there are no more responses.
+// nil in which case no response body will send. The t.Error() will be called
+// and the request answered with 500 status code if there are no more
+// responses. Responses from routes matching the request take precedence over
+// responses added with this method.
+//
+// Use Response() method to add responses with headers, cookies or trailers.
 func (tst *HTTPServer) Rsp(status int, rsp []byte) *HTTPServer {
-	tst.responses = append(tst.responses, response{
-		status: status,
-		body:   rsp,
-	})
-	tst.responseCnt = len(tst.responses)
+	tst.Response().Status(status).Body(rsp)
 	return tst
 }
 
@@ -102,24 +298,26 @@ func (tst *HTTPServer) URL() string { return tst.srv.URL }
 // greater than number or received requests.
 func (tst *HTTPServer) Request(n int) *http.Request {
 	tst.t.Helper()
-	if n >= 0 && n < len(tst.requests) {
-		return CloneHTTPRequest(tst.t, tst.requests[n])
+	if rec, ok := tst.record(n); ok {
+		return rec.request()
 	}
-	tst.t.Fatalf("no request with index %d recorded", n)
 	return nil
 }
 
 // ReqCount returns number of requests recorded by test server.
-func (tst *HTTPServer) ReqCount() int { return len(tst.requests) }
+func (tst *HTTPServer) ReqCount() int {
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
+	return len(tst.requests)
+}
 
 // Values returns URL query values of the nth received request. Calls t.Fatal()
 // if n is greater than number or received requests.
 func (tst *HTTPServer) Values(n int) url.Values {
 	tst.t.Helper()
-	if n >= 0 && n < len(tst.requests) {
-		return tst.requests[n].URL.Query()
+	if rec, ok := tst.record(n); ok {
+		return rec.req.URL.Query()
 	}
-	tst.t.Fatalf("no request with index %d recorded", n)
 	return url.Values{}
 }
 
@@ -127,22 +325,9 @@ func (tst *HTTPServer) Values(n int) url.Values {
 // greater than number or received requests.
 func (tst *HTTPServer) Body(n int) []byte {
 	tst.t.Helper()
-	if n >= 0 && n < len(tst.requests) {
-		req := tst.requests[n]
-		var buf bytes.Buffer
-		body, err := ioutil.ReadAll(io.TeeReader(req.Body, &buf))
-		if err != nil {
-			tst.t.Fatal(err)
-			return nil
-		}
-		if err = req.Body.Close(); err != nil {
-			tst.t.Fatal(err)
-			return nil
-		}
-		req.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
-		return body
+	if rec, ok := tst.record(n); ok {
+		return append([]byte{}, rec.body...)
 	}
-	tst.t.Fatalf("no request with index %d recorded", n)
 	return nil
 }
 
@@ -152,37 +337,112 @@ func (tst *HTTPServer) BodyString(n int) string {
 	return string(tst.Body(n))
 }
 
+// Proto returns protocol version (for example "HTTP/1.1" or "HTTP/2.0") the
+// nth request was made with. Calls t.Fatal() if n is greater than number
+// or received requests.
+func (tst *HTTPServer) Proto(n int) string {
+	tst.t.Helper()
+	if rec, ok := tst.record(n); ok {
+		return rec.req.Proto
+	}
+	return ""
+}
+
+// At returns the time the nth request was received. Calls t.Fatal() if n
+// is greater than number or received requests.
+func (tst *HTTPServer) At(n int) time.Time {
+	tst.t.Helper()
+	if rec, ok := tst.record(n); ok {
+		return rec.at
+	}
+	return time.Time{}
+}
+
 // Headers returns headers for given request index. Calls t.Fatal() if n is
 // greater than number or received requests.
 func (tst *HTTPServer) Headers(n int) http.Header {
 	tst.t.Helper()
-	if n >= 0 && n < len(tst.requests) {
-		return tst.requests[n].Header
+	if rec, ok := tst.record(n); ok {
+		return rec.req.Header.Clone()
 	}
-	tst.t.Fatalf("no request with index %d recorded", n)
 	return nil
 }
 
-// next returns the next response to return. Calls t.Fatal() if no more
-// responses to give.
-func (tst *HTTPServer) next() response {
+// record returns the nth recorded request. Calls t.Fatal() and returns false
+// if n is greater than number or received requests.
+func (tst *HTTPServer) record(n int) (record, bool) {
 	tst.t.Helper()
-	var rsp response
-	if len(tst.responses) == 0 {
-		tst.t.Fatal("no more responses to give")
-		return rsp
+	tst.mx.Lock()
+	if n >= 0 && n < len(tst.requests) {
+		rec := tst.requests[n]
+		tst.mx.Unlock()
+		return rec, true
+	}
+	tst.mx.Unlock()
+	tst.t.Fatalf("no request with index %d recorded", n)
+	return record{}, false
+}
+
+// next returns the next response or handler to answer the request with.
+// Responses and handlers from matching routes take precedence over
+// responses added with Rsp(). Requests to GraphQL endpoints are answered
+// only by the routes. Returns false if no more responses to give.
+//
+// Must be called with the lock held.
+func (tst *HTTPServer) next(req *http.Request, rms []routeMatch) (*Response, http.Handler, bool) {
+	if rsp, hnd, ok := tst.route(rms); ok {
+		if hnd != nil {
+			return nil, hnd, true
+		}
+		return rsp.clone(), nil, true
 	}
+	if _, ok := tst.graphql[req.URL.Path]; ok || len(tst.responses) == 0 {
+		return nil, nil, false
+	}
+	var rsp *Response
 	rsp, tst.responses = tst.responses[0], tst.responses[1:]
-	return rsp
+	return rsp.clone(), nil, true
 }
 
-// Close stops the test server and does cleanup. May be called multiple times.
-func (tst *HTTPServer) Close() error {
+// stop interrupts delayed and stalled responses and stops the test server.
+func (tst *HTTPServer) stop() {
+	tst.closeOnce.Do(func() { close(tst.done) })
 	tst.srv.Close()
-	for _, req := range tst.requests {
-		_ = req.Body.Close()
+}
+
+// unmatched reports request without response to give with t.Error().
+//
+// Must be called with the lock held.
+func (tst *HTTPServer) unmatched(rec record, rms []routeMatch) {
+	if _, ok := tst.graphql[rec.req.URL.Path]; ok {
+		gr, _ := parseGraphQL(rec.req, rec.body)
+		tst.t.Errorf(
+			"no graphql response for operation %q request %s %s",
+			gr.OperationName,
+			rec.req.Method,
+			rec.req.URL.RequestURI(),
+		)
+		return
 	}
+	if desc := tst.closest(rms); desc != "" {
+		tst.t.Errorf(
+			"no route matches request %s %s, closest route: %s",
+			rec.req.Method,
+			rec.req.URL.RequestURI(),
+			desc,
+		)
+		return
+	}
+	tst.t.Error("no more responses to give")
+}
+
+// Close stops the test server and does cleanup. May be called multiple times.
+func (tst *HTTPServer) Close() error {
+	tst.stop()
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
 	tst.requests = tst.requests[:0]
 	tst.responses = tst.responses[:0]
+	tst.routes = tst.routes[:0]
 	return nil
 }
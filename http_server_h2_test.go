@@ -0,0 +1,63 @@
+package testkit_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_WithHTTP2(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		opts []kit.HTTPServerOption
+	}{
+		{"h2", []kit.HTTPServerOption{kit.WithTLS(), kit.WithHTTP2()}},
+		{"h2c", []kit.HTTPServerOption{kit.WithHTTP2()}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			mck := &kit.TMock{}
+			mck.On("Cleanup", mock.Anything)
+			mck.On("Helper")
+
+			srv := kit.NewHTTPServer(mck, tc.opts...)
+			srv.Response().Trailer("X-Trailer", "abc").Body([]byte("h2"))
+
+			// --- When ---
+			rsp, err := srv.Client().Get(srv.URL())
+
+			// --- Then ---
+			mck.AssertExpectations(t)
+			require.NoError(t, err)
+			assert.Exactly(t, "HTTP/2.0", rsp.Proto)
+			assert.Exactly(t, "h2", string(getResponseBody(t, rsp)))
+			assert.Exactly(t, "abc", rsp.Trailer.Get("X-Trailer"))
+			assert.Exactly(t, "HTTP/2.0", srv.Proto(0))
+		})
+	}
+}
+
+func Test_HTTPServer_WithHTTP2_http1Client(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithHTTP2()).Rsp(http.StatusOK, nil)
+
+	// --- When ---
+	_, err := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, "HTTP/1.1", srv.Proto(0))
+}
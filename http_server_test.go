@@ -2,8 +2,10 @@ package testkit_test
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -112,3 +114,240 @@ func getResponseBody(t *testing.T, r *http.Response) []byte {
 	}
 	return body
 }
+
+func Test_HTTPServer_Route(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	users := srv.Route(kit.MatchMethod(http.MethodGet), kit.MatchPath("/users"))
+	users.Rsp(http.StatusOK, []byte("users"))
+	orders := srv.Route(kit.MatchPathPattern("^/orders/[0-9]+$"))
+	orders.Rsp(http.StatusOK, []byte("order"))
+	srv.Rsp(http.StatusAccepted, []byte("queue"))
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL() + "/orders/1")
+	rsp1, err1 := http.Get(srv.URL() + "/other")
+	rsp2, err2 := http.Get(srv.URL() + "/users")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	assert.Exactly(t, "order", string(getResponseBody(t, rsp0)))
+	assert.Exactly(t, http.StatusAccepted, rsp1.StatusCode)
+	assert.Exactly(t, "queue", string(getResponseBody(t, rsp1)))
+	assert.Exactly(t, "users", string(getResponseBody(t, rsp2)))
+	assert.Exactly(t, 1, users.Calls())
+	assert.Exactly(t, 1, orders.Calls())
+	assert.Exactly(t, 3, srv.ReqCount())
+}
+
+func Test_HTTPServer_Route_queryHeaderAndBody(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	rt := srv.Route(
+		kit.MatchQuery("k0", "v0"),
+		kit.MatchHeader("X-Test", "abc"),
+		kit.MatchBody(func(body []byte) bool {
+			return string(body) == "req body"
+		}),
+	).Rsp(http.StatusCreated, nil)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		srv.URL()+"/?k0=v0",
+		bytes.NewReader([]byte("req body")),
+	)
+	require.NoError(t, err)
+	req.Header.Set("X-Test", "abc")
+
+	// --- When ---
+	rsp, err := http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusCreated, rsp.StatusCode)
+	assert.Exactly(t, 1, rt.Calls())
+	assert.Exactly(t, "req body", srv.BodyString(0))
+}
+
+func Test_HTTPServer_Route_matcherCallsServer(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	first := kit.NewMatcher("first request", func(*http.Request, []byte) bool {
+		return srv.ReqCount() == 0
+	})
+	srv.Route(first).Rsp(http.StatusOK, []byte("first"))
+	srv.Rsp(http.StatusOK, []byte("other"))
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL())
+	rsp1, err1 := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	require.NoError(t, err1)
+	assert.Exactly(t, "first", string(getResponseBody(t, rsp0)))
+	assert.Exactly(t, "other", string(getResponseBody(t, rsp1)))
+}
+
+func Test_HTTPServer_Route_noMatch(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"no route matches request %s %s, closest route: %s",
+		http.MethodDelete,
+		"/users",
+		"method POST (no match), path /users (match)",
+	)
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Route(kit.MatchMethod(http.MethodGet), kit.MatchPath("/orders")).
+		Rsp(http.StatusOK, nil)
+	srv.Route(kit.MatchMethod(http.MethodPost), kit.MatchPath("/users")).
+		Rsp(http.StatusOK, nil)
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL()+"/users", nil)
+	require.NoError(t, err)
+
+	// --- When ---
+	rsp, err := http.DefaultClient.Do(req)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusInternalServerError, rsp.StatusCode)
+}
+
+func Test_HTTPServer_noMoreResponses(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Error", "no more responses to give")
+
+	srv := kit.NewHTTPServer(mck)
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusInternalServerError, rsp.StatusCode)
+	assert.Exactly(t, 1, srv.ReqCount())
+}
+
+func Test_HTTPServer_concurrentClients(t *testing.T) {
+	// --- Given ---
+	const clients = 20
+
+	srv := kit.NewHTTPServer(t)
+	users := srv.Route(kit.MatchPath("/users"))
+	orders := srv.Route(kit.MatchPath("/orders"))
+	for i := 0; i < clients; i++ {
+		users.Rsp(http.StatusOK, []byte("users"))
+		orders.Rsp(http.StatusOK, []byte("orders"))
+	}
+
+	// --- When ---
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*clients)
+	for i := 0; i < clients; i++ {
+		for _, pth := range []string{"/users", "/orders"} {
+			wg.Add(1)
+			go func(pth string) {
+				defer wg.Done()
+				rsp, err := http.Post(
+					srv.URL()+pth,
+					"",
+					bytes.NewReader([]byte(pth)),
+				)
+				if err != nil {
+					errs <- err
+					return
+				}
+				body, err := ioutil.ReadAll(rsp.Body)
+				_ = rsp.Body.Close()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if "/"+string(body) != pth {
+					errs <- fmt.Errorf("expected %s got /%s", pth, body)
+				}
+			}(pth)
+		}
+	}
+
+	// Inspect the server while it's serving requests.
+	for i := 0; i < clients; i++ {
+		if n := srv.ReqCount(); n > 0 {
+			_ = srv.Body(n - 1)
+			_ = srv.Headers(n - 1)
+			_ = srv.Request(n - 1)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	// --- Then ---
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Exactly(t, 2*clients, srv.ReqCount())
+	assert.Exactly(t, clients, users.Calls())
+	assert.Exactly(t, clients, orders.Calls())
+	for i := 0; i < srv.ReqCount(); i++ {
+		assert.Exactly(t, srv.Request(i).URL.Path, srv.BodyString(i))
+	}
+}
+
+func Test_HTTPServer_closeWhileServing(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Error", mock.Anything).Maybe()
+
+	srv := kit.NewHTTPServer(mck)
+	for i := 0; i < 10; i++ {
+		srv.Rsp(http.StatusOK, nil)
+	}
+
+	// --- When ---
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rsp, err := http.Get(srv.URL()); err == nil {
+				_ = rsp.Body.Close()
+			}
+		}()
+	}
+	assert.NoError(t, srv.Close())
+	assert.NoError(t, srv.Close())
+	wg.Wait()
+
+	// --- Then ---
+	assert.Exactly(t, 0, srv.ReqCount())
+}
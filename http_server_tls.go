@@ -0,0 +1,107 @@
+package testkit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// rawConnKey is the request context key of the TCP connection accepted
+// by the server before it was wrapped with TLS.
+type rawConnKey struct{}
+
+// tcpListener keeps the accepted TCP connections until the server wraps
+// them with TLS, so they can be reset by Response.ResetConn().
+type tcpListener struct {
+	net.Listener
+	conns sync.Map // Remote address to accepted *net.TCPConn.
+}
+
+func (l *tcpListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if tcp, ok := c.(*net.TCPConn); ok {
+		l.conns.Store(tcp.RemoteAddr().String(), tcp)
+	}
+	return c, err
+}
+
+// connContext adds to ctx the TCP connection underlying c.
+func (l *tcpListener) connContext(ctx context.Context, c net.Conn) context.Context {
+	if raw, ok := l.conns.LoadAndDelete(c.RemoteAddr().String()); ok {
+		return context.WithValue(ctx, rawConnKey{}, raw)
+	}
+	return ctx
+}
+
+// startTLS starts the server with TLS using certificates issued by
+// newly created certificate authority.
+func (tst *HTTPServer) startTLS() {
+	tst.t.Helper()
+	tst.ca = NewCA(tst.t)
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{tst.ca.ServerCert()},
+	}
+	if tst.mtls {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = tst.ca.CertPool()
+	}
+	lis := &tcpListener{Listener: tst.srv.Listener}
+	tst.srv.Listener = lis
+	tst.srv.Config.ConnContext = lis.connContext
+	tst.srv.TLS = cfg
+	tst.srv.StartTLS()
+
+	ccfg := tst.TLSConfig()
+	if tst.mtls {
+		ccfg.Certificates = []tls.Certificate{tst.ca.ClientCert("client")}
+	}
+	if tr, ok := tst.srv.Client().Transport.(*http.Transport); ok {
+		tr.TLSClientConfig = ccfg
+	}
+}
+
+// CA returns certificate authority which issued the server certificate.
+// Returns nil if the server does not serve HTTPS.
+func (tst *HTTPServer) CA() *CA { return tst.ca }
+
+// Client returns HTTP client configured to make requests to the server.
+// In TLS mode it trusts the server's certificate authority and in mutual
+// TLS mode presents client certificate with common name "client".
+func (tst *HTTPServer) Client() *http.Client { return tst.srv.Client() }
+
+// TLSConfig returns client TLS configuration trusting the server's
+// certificate authority. Calls t.Fatal() if the server does not serve HTTPS.
+func (tst *HTTPServer) TLSConfig() *tls.Config {
+	tst.t.Helper()
+	if tst.ca == nil {
+		tst.t.Fatal("server does not serve HTTPS")
+		return nil
+	}
+	return &tls.Config{RootCAs: tst.ca.CertPool()}
+}
+
+// ClientCert issues client certificate with common name cn trusted by the
+// server in mutual TLS mode. Calls t.Fatal() if the server does not
+// serve HTTPS.
+func (tst *HTTPServer) ClientCert(cn string) tls.Certificate {
+	tst.t.Helper()
+	if tst.ca == nil {
+		tst.t.Fatal("server does not serve HTTPS")
+		return tls.Certificate{}
+	}
+	return tst.ca.ClientCert(cn)
+}
+
+// PeerCert returns certificate presented by the client making the nth
+// request. Returns nil if the client did not present a certificate.
+// Calls t.Fatal() if n is greater than number or received requests.
+func (tst *HTTPServer) PeerCert(n int) *x509.Certificate {
+	tst.t.Helper()
+	if rec, ok := tst.record(n); ok {
+		return rec.peer
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package testkit_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_HTTPServer_WithTLS(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithTLS()).
+		Rsp(http.StatusOK, []byte("secure"))
+
+	// --- When ---
+	rsp, err := srv.Client().Get(srv.URL() + "/path")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, "secure", string(getResponseBody(t, rsp)))
+	assert.Contains(t, srv.URL(), "https://")
+	assert.Exactly(t, "https", srv.Request(0).URL.Scheme)
+	assert.NotNil(t, srv.Request(0).TLS)
+	assert.Nil(t, srv.PeerCert(0))
+	assert.True(t, srv.CA().Cert().IsCA)
+}
+
+func Test_HTTPServer_WithTLS_untrustedClient(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithTLS())
+
+	// --- When ---
+	_, err := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Error(t, err)
+	assert.Exactly(t, 0, srv.ReqCount())
+}
+
+func Test_HTTPServer_WithMTLS(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithMTLS())
+	srv.Rsp(http.StatusOK, nil).Rsp(http.StatusOK, nil)
+
+	cfg := srv.TLSConfig()
+	cfg.Certificates = []tls.Certificate{srv.ClientCert("other")}
+	cli := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+
+	// --- When ---
+	rsp0, err0 := srv.Client().Get(srv.URL())
+	rsp1, err1 := cli.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, http.StatusOK, rsp1.StatusCode)
+	assert.Exactly(t, "client", srv.PeerCert(0).Subject.CommonName)
+	assert.Exactly(t, "other", srv.PeerCert(1).Subject.CommonName)
+}
+
+func Test_HTTPServer_WithMTLS_noClientCert(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck, kit.WithMTLS())
+	cli := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: srv.TLSConfig()},
+	}
+
+	// --- When ---
+	_, err := cli.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Error(t, err)
+	assert.Exactly(t, 0, srv.ReqCount())
+}
@@ -0,0 +1,109 @@
+package testkit
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// NewHTTPSpy returns new instance of HTTPServer configured with options
+// answering requests with handler h while recording requests and served
+// responses. Routes and responses added to the server take precedence over
+// the handler. Requests answered by the handler are not counted as
+// expected requests. Use RspStatus, RspHeaders and RspBody to inspect
+// served responses.
+func NewHTTPSpy(t T, h http.Handler, opts ...HTTPServerOption) *HTTPServer {
+	t.Helper()
+	opts = append(opts, func(tst *HTTPServer) { tst.fallback = h })
+	return NewHTTPServer(t, opts...)
+}
+
+// NewHTTPProxySpy returns new instance of HTTPServer configured with
+// options acting as a reverse proxy to the upstream URL. See NewHTTPSpy
+// for details. Calls t.Fatal() if the upstream URL cannot be parsed.
+// Upstream errors are reported with t.Error() and answered with status
+// 502.
+func NewHTTPProxySpy(t T, upstream string, opts ...HTTPServerOption) *HTTPServer {
+	t.Helper()
+	u, err := url.Parse(upstream)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = u.Host
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+		t.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return NewHTTPSpy(t, proxy, opts...)
+}
+
+// rspTimeout is the default time response accessors wait for the response
+// to be served.
+const rspTimeout = 10 * time.Second
+
+// WithRspTimeout configures how long HTTPServer response accessors
+// (RspStatus, RspHeaders, RspBody) wait for the response to be served
+// before calling t.Fatalf(). Defaults to 10 seconds.
+func WithRspTimeout(d time.Duration) HTTPServerOption {
+	return func(tst *HTTPServer) { tst.rspTimeout = d }
+}
+
+// RspStatus returns status code of the response served for the nth
+// request or zero if nothing was written. Calls t.Fatal() if n is greater
+// than number or received requests.
+//
+// The response accessors wait until the response is fully served, see
+// WithRspTimeout.
+func (tst *HTTPServer) RspStatus(n int) int {
+	tst.t.Helper()
+	return tst.served(n).status
+}
+
+// RspHeaders returns headers of the response served for the nth request.
+// Calls t.Fatal() if n is greater than number or received requests.
+func (tst *HTTPServer) RspHeaders(n int) http.Header {
+	tst.t.Helper()
+	return tst.served(n).header.Clone()
+}
+
+// RspBody returns body of the response served for the nth request. Calls
+// t.Fatal() if n is greater than number or received requests.
+func (tst *HTTPServer) RspBody(n int) []byte {
+	tst.t.Helper()
+	return append([]byte{}, tst.served(n).body...)
+}
+
+// RspBodyString returns body of the response served for the nth request.
+// Calls t.Fatal() if n is greater than number or received requests.
+func (tst *HTTPServer) RspBodyString(n int) string {
+	tst.t.Helper()
+	return string(tst.RspBody(n))
+}
+
+// served waits until the response for the nth request is served and
+// returns it. Calls t.Fatalf() if the response is not served in time.
+func (tst *HTTPServer) served(n int) served {
+	tst.t.Helper()
+	rec, ok := tst.record(n)
+	if !ok {
+		return served{}
+	}
+	tmr := time.NewTimer(tst.rspTimeout)
+	defer tmr.Stop()
+	select {
+	case <-rec.fin:
+	case <-tmr.C:
+		tst.t.Fatalf("response to request %d not served within %s", n, tst.rspTimeout)
+		return served{}
+	}
+	tst.mx.Lock()
+	defer tst.mx.Unlock()
+	return rec.served()
+}
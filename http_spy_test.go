@@ -0,0 +1,166 @@
+package testkit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_NewHTTPSpy(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Handler", "real")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(append([]byte("echo: "), body...))
+	})
+	srv := kit.NewHTTPSpy(mck, h)
+
+	// --- When ---
+	rsp, err := http.Post(srv.URL()+"/items", "text/plain", bytes.NewReader([]byte("abc")))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusCreated, rsp.StatusCode)
+	assert.Exactly(t, "echo: abc", string(getResponseBody(t, rsp)))
+
+	assert.Exactly(t, 1, srv.ReqCount())
+	assert.Exactly(t, "abc", srv.BodyString(0))
+	assert.Exactly(t, "/items", srv.Request(0).URL.Path)
+	assert.Exactly(t, http.StatusCreated, srv.RspStatus(0))
+	assert.Exactly(t, "real", srv.RspHeaders(0).Get("X-Handler"))
+	assert.Exactly(t, []byte("echo: abc"), srv.RspBody(0))
+	assert.Exactly(t, "echo: abc", srv.RspBodyString(0))
+}
+
+func Test_NewHTTPSpy_responsesTakePrecedence(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := kit.NewHTTPSpy(mck, h)
+	srv.Route(kit.MatchPath("/fail")).Rsp(http.StatusServiceUnavailable, nil)
+
+	// --- When ---
+	rsp0, err0 := http.Get(srv.URL() + "/fail")
+	rsp1, err1 := http.Get(srv.URL() + "/fail")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	assert.Exactly(t, http.StatusServiceUnavailable, rsp0.StatusCode)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusOK, rsp1.StatusCode)
+	assert.Exactly(t, http.StatusServiceUnavailable, srv.RspStatus(0))
+	assert.Exactly(t, http.StatusOK, srv.RspStatus(1))
+}
+
+func Test_NewHTTPProxySpy(t *testing.T) {
+	// --- Given ---
+	var host string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer up.Close()
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPProxySpy(mck, up.URL+"/api")
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL() + "/users?a=1")
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusOK, rsp.StatusCode)
+	assert.Exactly(t, `{"path":"/api/users"}`, string(getResponseBody(t, rsp)))
+	assert.Exactly(t, up.Listener.Addr().String(), host)
+
+	assert.Exactly(t, "1", srv.Values(0).Get("a"))
+	assert.Exactly(t, http.StatusOK, srv.RspStatus(0))
+	assert.Exactly(t, "application/json", srv.RspHeaders(0).Get("Content-Type"))
+	assert.Exactly(t, `{"path":"/api/users"}`, srv.RspBodyString(0))
+}
+
+func Test_NewHTTPProxySpy_upstreamError(t *testing.T) {
+	// --- Given ---
+	up := httptest.NewServer(http.NotFoundHandler())
+	up.Close()
+
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Error", mock.Anything)
+
+	srv := kit.NewHTTPProxySpy(mck, up.URL)
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusBadGateway, rsp.StatusCode)
+	assert.Exactly(t, http.StatusBadGateway, srv.RspStatus(0))
+}
+
+func Test_HTTPServer_RspStatus_timeout(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Fatalf",
+		"response to request %d not served within %s",
+		0,
+		10*time.Millisecond,
+	)
+
+	release := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	srv := kit.NewHTTPSpy(mck, h, kit.WithRspTimeout(10*time.Millisecond))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rsp, err := http.Get(srv.URL())
+		if err == nil {
+			_ = rsp.Body.Close()
+		}
+	}()
+	kit.Wait(time.Second, func() bool { return srv.ReqCount() == 1 })
+
+	// --- When ---
+	got := srv.RspStatus(0)
+
+	// --- Then ---
+	close(release)
+	<-done
+	mck.AssertExpectations(t)
+	assert.Exactly(t, 0, got)
+}
@@ -0,0 +1,193 @@
+package testkit
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SSEEvent represents Server-Sent Event.
+type SSEEvent struct {
+	ID    string        // Event ID, not sent when empty.
+	Event string        // Event type, not sent when empty.
+	Data  string        // Event data, may contain new lines.
+	Retry time.Duration // Reconnection time, not sent when zero.
+}
+
+// Bytes returns event encoded in text/event-stream format.
+func (e SSEEvent) Bytes() []byte {
+	var buf bytes.Buffer
+	if e.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", e.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// Chunks sets response body to be sent as a sequence of chunks. Every
+// chunk is flushed to the client, use Interval() to set the delay between
+// them. Without Content-Length header HTTP/1.1 responses use chunked
+// transfer encoding.
+func (rsp *Response) Chunks(chunks ...[]byte) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.chunks = append(rsp.chunks, chunks...)
+	return rsp
+}
+
+// Stream makes the response to send chunks received from ch. Every chunk is
+// flushed to the client as soon as it's received. The response is finished
+// when ch is closed. It is sent after chunks added with Chunks().
+func (rsp *Response) Stream(ch <-chan []byte) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.stream = ch
+	return rsp
+}
+
+// SSE makes the response to send Server-Sent Events. It sets Content-Type
+// header to "text/event-stream" and every event is flushed to the client,
+// use Interval() to set the delay between them.
+func (rsp *Response) SSE(events ...SSEEvent) *Response {
+	rsp.ContentType("text/event-stream")
+	for _, e := range events {
+		rsp.Chunks(e.Bytes())
+	}
+	return rsp
+}
+
+// SSEStream makes the response to send Server-Sent Events received from ch.
+// It sets Content-Type header to "text/event-stream" and every event is
+// flushed to the client as soon as it's received. The response is finished
+// when ch is closed. It is sent after events added with SSE().
+func (rsp *Response) SSEStream(ch <-chan SSEEvent) *Response {
+	rsp.ContentType("text/event-stream")
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.events = ch
+	return rsp
+}
+
+// Interval sets the delay between chunks or events.
+func (rsp *Response) Interval(d time.Duration) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.pause = d
+	return rsp
+}
+
+// LongPoll makes the server hold the request until the body is received
+// from ch. The response is sent with the configured status and received body.
+// When ch is closed or timeout (if greater than zero) elapses the response
+// with status 204 and no body is sent.
+func (rsp *Response) LongPoll(ch <-chan []byte, timeout time.Duration) *Response {
+	rsp.mx.Lock()
+	defer rsp.mx.Unlock()
+	rsp.poll = ch
+	rsp.pollTimeout = timeout
+	return rsp
+}
+
+// streaming returns true if response body is a stream.
+func (rsp *Response) streaming() bool {
+	return len(rsp.chunks) > 0 || rsp.stream != nil || rsp.events != nil
+}
+
+// awaitPoll waits for the long poll body. Returns false if request was
+// canceled or server is closing.
+func (rsp *Response) awaitPoll(req *http.Request, done <-chan struct{}) bool {
+	var timeout <-chan time.Time
+	if rsp.pollTimeout > 0 {
+		tmr := time.NewTimer(rsp.pollTimeout)
+		defer tmr.Stop()
+		timeout = tmr.C
+	}
+	select {
+	case body, ok := <-rsp.poll:
+		if ok {
+			rsp.body = body
+			return true
+		}
+	case <-timeout:
+	case <-req.Context().Done():
+		return false
+	case <-done:
+		return false
+	}
+	rsp.status = http.StatusNoContent
+	rsp.body = nil
+	return true
+}
+
+// writeStream writes chunks and streams to w flushing after every write.
+// Returns false if writing was interrupted or failed. Calls t.Error()
+// on error.
+func (rsp *Response) writeStream(w http.ResponseWriter, req *http.Request, done <-chan struct{}) bool {
+	flush := func(chunk []byte) bool {
+		if _, err := w.Write(chunk); err != nil {
+			rsp.t.Error(err)
+			return false
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return true
+	}
+
+	var sent bool
+	for _, chunk := range rsp.chunks {
+		if sent && !wait(rsp.pause, req, done) {
+			return false
+		}
+		if !flush(chunk) {
+			return false
+		}
+		sent = true
+	}
+
+	// Flush headers so the client sees the response before the first
+	// streamed chunk.
+	if !sent {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	stream, events := rsp.stream, rsp.events
+	for stream != nil || events != nil {
+		var chunk []byte
+		select {
+		case c, ok := <-stream:
+			if !ok {
+				stream = nil
+				continue
+			}
+			chunk = c
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			chunk = e.Bytes()
+		case <-req.Context().Done():
+			return false
+		case <-done:
+			return false
+		}
+		if !flush(chunk) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package testkit_test
+
+import (
+	"bufio"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_SSEEvent_Bytes(t *testing.T) {
+	// --- Given ---
+	e := kit.SSEEvent{
+		ID:    "1",
+		Event: "update",
+		Data:  "line1\nline2",
+		Retry: time.Second,
+	}
+
+	// --- When ---
+	got := e.Bytes()
+
+	// --- Then ---
+	exp := "id: 1\nevent: update\nretry: 1000\ndata: line1\ndata: line2\n\n"
+	assert.Exactly(t, exp, string(got))
+}
+
+func Test_Response_Chunks(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().
+		Chunks([]byte("abc"), []byte("def")).
+		Interval(30 * time.Millisecond)
+
+	// --- When ---
+	start := time.Now()
+	rsp, err := http.Get(srv.URL())
+	require.NoError(t, err)
+	body := getResponseBody(t, rsp)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, []string{"chunked"}, rsp.TransferEncoding)
+	assert.Exactly(t, "abcdef", string(body))
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+}
+
+func Test_Response_Stream(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	ch := make(chan []byte)
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().Stream(ch)
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL())
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+	rdr := bufio.NewReader(rsp.Body)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+
+	ch <- []byte("line1\n")
+	line, err := rdr.ReadString('\n')
+	require.NoError(t, err)
+	assert.Exactly(t, "line1\n", line)
+
+	ch <- []byte("line2\n")
+	line, err = rdr.ReadString('\n')
+	require.NoError(t, err)
+	assert.Exactly(t, "line2\n", line)
+
+	close(ch)
+	_, err = rdr.ReadString('\n')
+	assert.Error(t, err)
+}
+
+func Test_Response_SSE(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	ch := make(chan kit.SSEEvent)
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().
+		SSE(kit.SSEEvent{ID: "1", Data: "first"}).
+		SSEStream(ch)
+
+	// --- When ---
+	rsp, err := http.Get(srv.URL())
+	require.NoError(t, err)
+	go func() {
+		ch <- kit.SSEEvent{ID: "2", Event: "update", Data: "second"}
+		close(ch)
+	}()
+	body := getResponseBody(t, rsp)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, "text/event-stream", rsp.Header.Get("Content-Type"))
+	exp := "id: 1\ndata: first\n\nid: 2\nevent: update\ndata: second\n\n"
+	assert.Exactly(t, exp, string(body))
+}
+
+func Test_Response_LongPoll(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+
+	ch := make(chan []byte, 1)
+	srv := kit.NewHTTPServer(mck)
+	srv.Response().LongPoll(ch, 0)
+	srv.Response().LongPoll(make(chan []byte), 20*time.Millisecond)
+
+	// --- When ---
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ch <- []byte("event")
+	}()
+	rsp0, err0 := http.Get(srv.URL())
+	rsp1, err1 := http.Get(srv.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err0)
+	require.NoError(t, err1)
+	assert.Exactly(t, http.StatusOK, rsp0.StatusCode)
+	assert.Exactly(t, "event", string(getResponseBody(t, rsp0)))
+	assert.Exactly(t, http.StatusNoContent, rsp1.StatusCode)
+}
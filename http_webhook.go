@@ -0,0 +1,253 @@
+package testkit
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookHost is the host used to deliver webhooks to http.Handler.
+const webhookHost = "webhook.test"
+
+// webhookTimeout is the default webhook delivery attempt timeout.
+const webhookTimeout = 10 * time.Second
+
+// WebhookAttempt represents single webhook delivery attempt recorded by
+// Webhook.
+type WebhookAttempt struct {
+	Delivery int           // Delivery index.
+	Attempt  int           // Attempt number within the delivery, starting at 1.
+	Request  *http.Request // Sent request without the body.
+	Body     []byte        // Sent request body.
+	Status   int           // Response status code, zero on transport error.
+	Header   http.Header   // Response headers.
+	Response []byte        // Response body.
+	Err      error         // Transport error.
+	At       time.Time     // The time the attempt was made.
+}
+
+// Acknowledged returns true if the attempt was answered with 2xx status.
+func (wa WebhookAttempt) Acknowledged() bool {
+	return wa.Err == nil && wa.Status >= 200 && wa.Status < 300
+}
+
+// String returns attempt outcome description.
+func (wa WebhookAttempt) String() string {
+	if wa.Err != nil {
+		return wa.Err.Error()
+	}
+	return fmt.Sprintf("status %d", wa.Status)
+}
+
+// Webhook represents webhook dispatcher delivering signed payloads to the
+// endpoint under test.
+//
+// Webhook is a builder, every configuration method returns it so the calls
+// can be chained.
+//
+//	wh := kit.NewHandlerWebhook(t, handler, "/webhooks/github").
+//		Header("X-GitHub-Event", "push").
+//		GitHub(secret).
+//		Retry(3, 10*time.Millisecond)
+//
+//	wh.SendJSON(event)
+//
+// Deliveries not answered with 2xx status are retried with exponential
+// backoff. Redirects are not followed, so 3xx status is a failed attempt.
+// Every attempt is recorded, deliveries never acknowledged are reported
+// with t.Errorf().
+type Webhook struct {
+	t        T                                              // Test state manager.
+	url      string                                         // Endpoint URL.
+	cli      *http.Client                                   // HTTP client.
+	header   http.Header                                    // Request headers.
+	signers  []func(hdr http.Header, body []byte, at int64) // Request signers.
+	ts       time.Time                                      // Fixed signature timestamp.
+	retries  int                                            // Number of retries.
+	backoff  time.Duration                                  // Initial retry backoff.
+	mx       sync.Mutex                                     // Guards fields below.
+	sent     int                                            // Number of deliveries.
+	attempts []WebhookAttempt                               // Recorded attempts.
+}
+
+// NewWebhook returns new instance of Webhook delivering payloads to URL u.
+// Delivery attempts time out after 10 seconds, use Timeout to change it.
+func NewWebhook(t T, u string) *Webhook {
+	cli := &http.Client{
+		Timeout: webhookTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &Webhook{
+		t:      t,
+		url:    u,
+		cli:    cli,
+		header: make(http.Header),
+	}
+}
+
+// NewHandlerWebhook returns new instance of Webhook delivering payloads
+// in-process to handler h at path pth.
+func NewHandlerWebhook(t T, h http.Handler, pth string) *Webhook {
+	wh := NewWebhook(t, "http://"+webhookHost+"/"+strings.TrimLeft(pth, "/"))
+	wh.cli.Transport = HandlerTransport(h, webhookHost)
+	return wh
+}
+
+// Client sets HTTP client used to deliver payloads to the URL.
+func (wh *Webhook) Client(cli *http.Client) *Webhook {
+	wh.cli = cli
+	return wh
+}
+
+// Timeout sets delivery attempt timeout.
+func (wh *Webhook) Timeout(d time.Duration) *Webhook {
+	wh.cli.Timeout = d
+	return wh
+}
+
+// Header sets request header.
+func (wh *Webhook) Header(key, value string) *Webhook {
+	wh.header.Set(key, value)
+	return wh
+}
+
+// HMAC signs requests setting header to prefix followed by hex encoded
+// HMAC-SHA256 of the payload signed with secret.
+func (wh *Webhook) HMAC(header, prefix string, secret []byte) *Webhook {
+	wh.signers = append(wh.signers, func(hdr http.Header, body []byte, _ int64) {
+		hdr.Set(header, prefix+HMACSHA256(secret, body))
+	})
+	return wh
+}
+
+// GitHub signs requests the way GitHub does, setting X-Hub-Signature-256
+// header to "sha256=" followed by HMAC-SHA256 of the payload.
+func (wh *Webhook) GitHub(secret []byte) *Webhook {
+	return wh.HMAC("X-Hub-Signature-256", "sha256=", secret)
+}
+
+// Stripe signs requests the way Stripe does, setting Stripe-Signature
+// header to "t=<timestamp>,v1=<signature>" where signature is HMAC-SHA256
+// of the timestamp and the payload joined with a dot. The timestamp is the
+// attempt time, use Timestamp to set it.
+func (wh *Webhook) Stripe(secret []byte) *Webhook {
+	wh.signers = append(wh.signers, func(hdr http.Header, body []byte, at int64) {
+		ts := strconv.FormatInt(at, 10)
+		sig := HMACSHA256(secret, append([]byte(ts+"."), body...))
+		hdr.Set("Stripe-Signature", "t="+ts+",v1="+sig)
+	})
+	return wh
+}
+
+// Timestamp sets the time used in request signatures instead of the
+// attempt time. Use it to test rejection of stale deliveries.
+func (wh *Webhook) Timestamp(ts time.Time) *Webhook {
+	wh.ts = ts
+	return wh
+}
+
+// Retry makes the Webhook retry deliveries not answered with 2xx status
+// up to retries times. The first retry is made after backoff, every next
+// one after twice the previous backoff.
+func (wh *Webhook) Retry(retries int, backoff time.Duration) *Webhook {
+	wh.retries = retries
+	wh.backoff = backoff
+	return wh
+}
+
+// Send delivers payload with POST request. Unless set with Header the
+// Content-Type is "application/json". Returns true if the delivery was
+// acknowledged, otherwise calls t.Errorf() and returns false.
+func (wh *Webhook) Send(payload []byte) bool {
+	wh.t.Helper()
+	wh.mx.Lock()
+	idx := wh.sent
+	wh.sent++
+	wh.mx.Unlock()
+
+	var last WebhookAttempt
+	backoff := wh.backoff
+	for i := 0; i <= wh.retries; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		last = wh.attempt(idx, i+1, payload)
+		wh.mx.Lock()
+		wh.attempts = append(wh.attempts, last)
+		wh.mx.Unlock()
+		if last.Acknowledged() {
+			return true
+		}
+	}
+	wh.t.Errorf(
+		"webhook delivery %d to %s not acknowledged after %d attempts, last: %s",
+		idx,
+		wh.url,
+		wh.retries+1,
+		last,
+	)
+	return false
+}
+
+// SendJSON delivers v marshalled with ToJSON(). See Send for details.
+// Calls t.Fatal() if v cannot be marshalled.
+func (wh *Webhook) SendJSON(v interface{}) bool {
+	wh.t.Helper()
+	return wh.Send(ToJSON(wh.t, v))
+}
+
+// Attempts returns all recorded delivery attempts.
+func (wh *Webhook) Attempts() []WebhookAttempt {
+	wh.mx.Lock()
+	defer wh.mx.Unlock()
+	return append([]WebhookAttempt{}, wh.attempts...)
+}
+
+// attempt makes delivery attempt and returns it.
+func (wh *Webhook) attempt(idx, n int, payload []byte) WebhookAttempt {
+	wa := WebhookAttempt{
+		Delivery: idx,
+		Attempt:  n,
+		Body:     payload,
+		At:       time.Now(),
+	}
+	req, err := http.NewRequest(http.MethodPost, wh.url, bytes.NewReader(payload))
+	if err != nil {
+		wa.Err = err
+		return wa
+	}
+	req.Header = wh.header.Clone()
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	at := wa.At
+	if !wh.ts.IsZero() {
+		at = wh.ts
+	}
+	for _, sign := range wh.signers {
+		sign(req.Header, payload, at.Unix())
+	}
+	wa.Request = req.Clone(req.Context())
+	wa.Request.Body = nil
+
+	rsp, err := wh.cli.Do(req)
+	if err != nil {
+		wa.Err = err
+		return wa
+	}
+	defer rsp.Body.Close()
+	wa.Status = rsp.StatusCode
+	wa.Header = rsp.Header
+	if wa.Response, err = ioutil.ReadAll(rsp.Body); err != nil {
+		wa.Err = err
+	}
+	return wa
+}
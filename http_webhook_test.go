@@ -0,0 +1,228 @@
+package testkit_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_Webhook_GitHub(t *testing.T) {
+	// --- Given ---
+	secret := []byte("secret")
+	srv := kit.NewHTTPServer(t).
+		RequireAuth(kit.HMACAuth("X-Hub-Signature-256", "sha256=", secret)).
+		Rsp(http.StatusNoContent, nil)
+
+	wh := kit.NewWebhook(t, srv.URL()+"/hooks").
+		Header("X-GitHub-Event", "push").
+		GitHub(secret)
+
+	// --- When ---
+	ok := wh.SendJSON(map[string]string{"ref": "main"})
+
+	// --- Then ---
+	assert.True(t, ok)
+	assert.Exactly(t, `{"ref":"main"}`, srv.BodyString(0))
+	assert.Exactly(t, "push", srv.Headers(0).Get("X-GitHub-Event"))
+	assert.Exactly(t, "application/json", srv.Headers(0).Get("Content-Type"))
+
+	atts := wh.Attempts()
+	require.Len(t, atts, 1)
+	assert.Exactly(t, 0, atts[0].Delivery)
+	assert.Exactly(t, 1, atts[0].Attempt)
+	assert.Exactly(t, http.StatusNoContent, atts[0].Status)
+	assert.Exactly(t, []byte(`{"ref":"main"}`), atts[0].Body)
+	exp := "sha256=" + kit.HMACSHA256(secret, []byte(`{"ref":"main"}`))
+	assert.Exactly(t, exp, atts[0].Request.Header.Get("X-Hub-Signature-256"))
+	assert.True(t, atts[0].Acknowledged())
+}
+
+func Test_Webhook_Stripe(t *testing.T) {
+	// --- Given ---
+	secret := []byte("whsec")
+	ts := time.Unix(1700000000, 0)
+	var got string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Stripe-Signature")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wh := kit.NewHandlerWebhook(t, h, "/stripe").Stripe(secret).Timestamp(ts)
+
+	// --- When ---
+	ok := wh.Send([]byte(`{"id":"evt_1"}`))
+
+	// --- Then ---
+	assert.True(t, ok)
+	sig := kit.HMACSHA256(secret, []byte(`1700000000.{"id":"evt_1"}`))
+	assert.Exactly(t, "t=1700000000,v1="+sig, got)
+	assert.Exactly(t, "/stripe", wh.Attempts()[0].Request.URL.Path)
+}
+
+func Test_Webhook_Retry(t *testing.T) {
+	// --- Given ---
+	var calls int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("busy " + strconv.Itoa(calls)))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})
+
+	wh := kit.NewHandlerWebhook(t, h, "hook").Retry(3, 5*time.Millisecond)
+
+	// --- When ---
+	ok := wh.Send([]byte("payload"))
+
+	// --- Then ---
+	assert.True(t, ok)
+	atts := wh.Attempts()
+	require.Len(t, atts, 3)
+	assert.Exactly(t, 1, atts[0].Attempt)
+	assert.Exactly(t, http.StatusServiceUnavailable, atts[0].Status)
+	assert.Exactly(t, []byte("busy 1"), atts[0].Response)
+	assert.Exactly(t, 2, atts[1].Attempt)
+	assert.Exactly(t, 3, atts[2].Attempt)
+	assert.Exactly(t, http.StatusOK, atts[2].Status)
+	assert.Exactly(t, []byte("payload"), atts[2].Response)
+	assert.True(t, atts[1].At.Sub(atts[0].At) >= 5*time.Millisecond)
+	assert.True(t, atts[2].At.Sub(atts[1].At) >= 10*time.Millisecond)
+}
+
+func Test_Webhook_notAcknowledged(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"webhook delivery %d to %s not acknowledged after %d attempts, last: %s",
+		0,
+		"http://webhook.test/hook",
+		2,
+		mock.MatchedBy(func(wa kit.WebhookAttempt) bool {
+			return wa.String() == "status 400"
+		}),
+	)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	wh := kit.NewHandlerWebhook(mck, h, "/hook").Retry(1, 0)
+
+	// --- When ---
+	got := wh.Send(nil)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.False(t, got)
+	atts := wh.Attempts()
+	require.Len(t, atts, 2)
+	assert.Exactly(t, 0, atts[1].Delivery)
+	assert.Exactly(t, 2, atts[1].Attempt)
+}
+
+func Test_Webhook_transportError(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"webhook delivery %d to %s not acknowledged after %d attempts, last: %s",
+		0,
+		mock.Anything,
+		1,
+		mock.Anything,
+	)
+
+	srv := kit.NewHTTPServer(t)
+	u := srv.URL()
+	require.NoError(t, srv.Close())
+
+	wh := kit.NewWebhook(mck, u)
+
+	// --- When ---
+	got := wh.Send([]byte("{}"))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.False(t, got)
+	atts := wh.Attempts()
+	require.Len(t, atts, 1)
+	assert.Error(t, atts[0].Err)
+	assert.Exactly(t, 0, atts[0].Status)
+	assert.False(t, atts[0].Acknowledged())
+}
+
+func Test_Webhook_timeout(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"webhook delivery %d to %s not acknowledged after %d attempts, last: %s",
+		0,
+		mock.Anything,
+		1,
+		mock.Anything,
+	)
+
+	srv := kit.NewHTTPServer(t)
+	srv.Response().Stall()
+
+	wh := kit.NewWebhook(mck, srv.URL()).Timeout(50 * time.Millisecond)
+
+	// --- When ---
+	got := wh.Send([]byte("{}"))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.False(t, got)
+	atts := wh.Attempts()
+	require.Len(t, atts, 1)
+	assert.Error(t, atts[0].Err)
+	assert.False(t, atts[0].Acknowledged())
+}
+
+func Test_Webhook_redirect(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"webhook delivery %d to %s not acknowledged after %d attempts, last: %s",
+		0,
+		mock.Anything,
+		1,
+		mock.MatchedBy(func(wa kit.WebhookAttempt) bool {
+			return wa.String() == "status 302"
+		}),
+	)
+
+	srv := kit.NewHTTPServer(t)
+	srv.Response().Status(http.StatusFound).Header("Location", "/other")
+
+	wh := kit.NewWebhook(mck, srv.URL())
+
+	// --- When ---
+	got := wh.Send([]byte("{}"))
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.False(t, got)
+	assert.Exactly(t, 1, srv.ReqCount())
+	atts := wh.Attempts()
+	require.Len(t, atts, 1)
+	assert.Exactly(t, "/other", atts[0].Header.Get("Location"))
+}
@@ -0,0 +1,142 @@
+package testkit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+)
+
+// CA represents throwaway certificate authority for tests.
+type CA struct {
+	cert *x509.Certificate // CA certificate.
+	key  crypto.Signer     // CA private key.
+	t    T                 // Test state manager.
+}
+
+// NewCA returns new throwaway certificate authority valid for one day.
+// Calls t.Fatal() on error.
+func NewCA(t T) *CA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          serial(t),
+		Subject:               pkix.Name{CommonName: "testkit CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	return &CA{cert: cert, key: key, t: t}
+}
+
+// Cert returns CA certificate.
+func (ca *CA) Cert() *x509.Certificate { return ca.cert }
+
+// CertPEM returns PEM encoded CA certificate.
+func (ca *CA) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// CertPool returns certificate pool with the CA certificate.
+func (ca *CA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// ServerCert issues server certificate for hosts signed by the CA. The hosts
+// may be IP addresses or DNS names. When no hosts are given the certificate
+// is issued for "localhost", "127.0.0.1" and "::1". Calls t.Fatal() on error.
+func (ca *CA) ServerCert(hosts ...string) tls.Certificate {
+	ca.t.Helper()
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	tpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0]},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, h)
+		}
+	}
+	return ca.issue(tpl)
+}
+
+// ClientCert issues client certificate with common name cn signed by
+// the CA. Calls t.Fatal() on error.
+func (ca *CA) ClientCert(cn string) tls.Certificate {
+	ca.t.Helper()
+	tpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	return ca.issue(tpl)
+}
+
+// issue issues certificate based on template tpl signed by the CA.
+// Calls t.Fatal() on error.
+func (ca *CA) issue(tpl *x509.Certificate) tls.Certificate {
+	ca.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatal(err)
+		return tls.Certificate{}
+	}
+	tpl.SerialNumber = serial(ca.t)
+	tpl.NotBefore = time.Now().Add(-time.Hour)
+	tpl.NotAfter = time.Now().Add(24 * time.Hour)
+	tpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		ca.t.Fatal(err)
+		return tls.Certificate{}
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		ca.t.Fatal(err)
+		return tls.Certificate{}
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+}
+
+// serial returns random certificate serial number. Calls t.Fatal() on error.
+func serial(t T) *big.Int {
+	t.Helper()
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	return n
+}
@@ -0,0 +1,240 @@
+package testkit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WSOpcode represents WebSocket frame opcode.
+type WSOpcode int
+
+// WebSocket frame opcodes.
+const (
+	WSContinuation WSOpcode = 0x0
+	WSText         WSOpcode = 0x1
+	WSBinary       WSOpcode = 0x2
+	WSClose        WSOpcode = 0x8
+	WSPing         WSOpcode = 0x9
+	WSPong         WSOpcode = 0xA
+)
+
+// String returns opcode name.
+func (op WSOpcode) String() string {
+	switch op {
+	case WSContinuation:
+		return "continuation"
+	case WSText:
+		return "text"
+	case WSBinary:
+		return "binary"
+	case WSClose:
+		return "close"
+	case WSPing:
+		return "ping"
+	case WSPong:
+		return "pong"
+	}
+	return fmt.Sprintf("opcode(%d)", int(op))
+}
+
+// WSFrame represents WebSocket frame. Fragmented messages are represented
+// as a single frame with the opcode of the first fragment.
+type WSFrame struct {
+	Conn      int      // Index of the connection the frame was seen on.
+	Inbound   bool     // True for frames received from the client.
+	Opcode    WSOpcode // Frame opcode.
+	Payload   []byte   // Frame payload (for close frames without the code).
+	CloseCode int      // Close code for close frames.
+}
+
+// String returns frame description.
+func (f WSFrame) String() string {
+	if f.Opcode == WSClose {
+		return fmt.Sprintf("close(%d, %q)", f.CloseCode, f.Payload)
+	}
+	if f.Opcode == WSText {
+		return fmt.Sprintf("text(%q)", f.Payload)
+	}
+	return fmt.Sprintf("%s(%x)", f.Opcode, f.Payload)
+}
+
+// wsGUID is used to compute Sec-WebSocket-Accept header value.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxPayload is the maximum size of WebSocket message payload.
+const wsMaxPayload = 32 << 20
+
+// wsCloseTooBig is the close code sent when message is too big to process.
+const wsCloseTooBig = 1009
+
+// errWSProtocol is returned when peer violates WebSocket protocol.
+var errWSProtocol = errors.New("websocket protocol error")
+
+// errWSTooBig is returned when message payload exceeds wsMaxPayload.
+var errWSTooBig = errors.New("websocket message too big")
+
+// wsAccept returns Sec-WebSocket-Accept header value for the key.
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// wsKey returns random Sec-WebSocket-Key header value.
+func wsKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// wsReadFrame reads single WebSocket frame from r. Returns frame, FIN bit
+// and error if any. Returns errWSTooBig if the payload exceeds
+// wsMaxPayload.
+func wsReadFrame(r *bufio.Reader) (WSFrame, bool, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return WSFrame{}, false, err
+	}
+	fin := hdr[0]&0x80 != 0
+	op := WSOpcode(hdr[0] & 0x0F)
+	masked := hdr[1]&0x80 != 0
+
+	size := uint64(hdr[1] & 0x7F)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return WSFrame{}, false, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return WSFrame{}, false, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if size > wsMaxPayload {
+		return WSFrame{}, false, errWSTooBig
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return WSFrame{}, false, err
+		}
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return WSFrame{}, false, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	frm := WSFrame{Opcode: op, Payload: payload}
+	if op == WSClose {
+		if len(payload) == 1 {
+			return WSFrame{}, false, errWSProtocol
+		}
+		if len(payload) >= 2 {
+			frm.CloseCode = int(binary.BigEndian.Uint16(payload))
+			frm.Payload = payload[2:]
+		}
+	}
+	return frm, fin, nil
+}
+
+// wsReadMessage reads WebSocket message from r assembling fragmented
+// messages. Control frames interleaved with fragments are passed to ctl.
+// Returns errWSTooBig if the message payload exceeds wsMaxPayload.
+func wsReadMessage(r *bufio.Reader, ctl func(WSFrame) error) (WSFrame, error) {
+	var msg WSFrame
+	var started bool
+	for {
+		frm, fin, err := wsReadFrame(r)
+		if err != nil {
+			return WSFrame{}, err
+		}
+		if frm.Opcode >= WSClose {
+			if !started {
+				return frm, nil
+			}
+			if err := ctl(frm); err != nil {
+				return WSFrame{}, err
+			}
+			continue
+		}
+		if !started {
+			if frm.Opcode == WSContinuation {
+				return WSFrame{}, errWSProtocol
+			}
+			msg, started = frm, true
+		} else {
+			if frm.Opcode != WSContinuation {
+				return WSFrame{}, errWSProtocol
+			}
+			if len(msg.Payload)+len(frm.Payload) > wsMaxPayload {
+				return WSFrame{}, errWSTooBig
+			}
+			msg.Payload = append(msg.Payload, frm.Payload...)
+		}
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// wsWriteFrame writes single, final WebSocket frame to w. When mask is true
+// the payload is masked with random key as required for client frames.
+func wsWriteFrame(w io.Writer, frm WSFrame, mask bool) error {
+	payload := frm.Payload
+	if frm.Opcode == WSClose && frm.CloseCode != 0 {
+		p := make([]byte, 2, 2+len(payload))
+		binary.BigEndian.PutUint16(p, uint16(frm.CloseCode))
+		payload = append(p, payload...)
+	}
+
+	buf := []byte{0x80 | byte(frm.Opcode)}
+	var mbit byte
+	if mask {
+		mbit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, mbit|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, mbit|126, 0, 0)
+		binary.BigEndian.PutUint16(buf[2:], uint16(n))
+	default:
+		buf = append(buf, mbit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[2:], uint64(n))
+	}
+
+	if mask {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ key[i%4]
+		}
+		payload = masked
+	}
+
+	buf = append(buf, payload...)
+	_, err := w.Write(buf)
+	return err
+}
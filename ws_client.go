@@ -0,0 +1,176 @@
+package testkit
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// WSConn represents client side of WebSocket connection.
+type WSConn struct {
+	conn net.Conn      // The connection.
+	r    *bufio.Reader // Connection reader.
+	t    T             // Test state manager.
+}
+
+// DialWS connects to WebSocket server at URL u sending upgrade request with
+// additional headers hdr which may be nil. The "wss" and "https" URLs are
+// dialed with TLS trusting system certificate authorities, use DialWSTLS to
+// connect to servers with other certificates. It registers cleanup
+// function with t closing the connection. Calls t.Fatal() on error.
+func DialWS(t T, u string, hdr http.Header) *WSConn {
+	t.Helper()
+	return DialWSTLS(t, u, nil, hdr)
+}
+
+// DialWSTLS works like DialWS but dials "wss" and "https" URLs with TLS
+// configuration cfg. Use HTTPServer.TLSConfig() to trust the test server
+// certificate authority.
+func DialWSTLS(t T, u string, cfg *tls.Config, hdr http.Header) *WSConn {
+	t.Helper()
+	pu, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+
+	var secure bool
+	switch pu.Scheme {
+	case "ws", "http":
+		pu.Scheme = "http"
+	case "wss", "https":
+		pu.Scheme, secure = "https", true
+	default:
+		t.Fatalf("unsupported websocket URL scheme %s", pu.Scheme)
+		return nil
+	}
+
+	addr := pu.Host
+	if pu.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		addr = net.JoinHostPort(pu.Hostname(), port)
+	}
+
+	var conn net.Conn
+	if secure {
+		conn, err = tls.Dial("tcp", addr, cfg)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	key, err := wsKey()
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodGet, pu.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	for k, vs := range hdr {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+		return nil
+	}
+
+	r := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected websocket upgrade got status %d", rsp.StatusCode)
+		return nil
+	}
+	if got := rsp.Header.Get("Sec-WebSocket-Accept"); got != wsAccept(key) {
+		t.Fatalf("invalid Sec-WebSocket-Accept header %q", got)
+		return nil
+	}
+
+	return &WSConn{conn: conn, r: r, t: t}
+}
+
+// WriteText sends text message. Calls t.Fatal() on error.
+func (c *WSConn) WriteText(msg string) {
+	c.t.Helper()
+	c.WriteFrame(WSFrame{Opcode: WSText, Payload: []byte(msg)})
+}
+
+// WriteBinary sends binary message. Calls t.Fatal() on error.
+func (c *WSConn) WriteBinary(msg []byte) {
+	c.t.Helper()
+	c.WriteFrame(WSFrame{Opcode: WSBinary, Payload: msg})
+}
+
+// WriteFrame sends the frame. Calls t.Fatal() on error.
+func (c *WSConn) WriteFrame(frm WSFrame) {
+	c.t.Helper()
+	if err := wsWriteFrame(c.conn, frm, true); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// Read reads next message or control frame. Messages exceeding 32 MiB are
+// answered with close frame with code 1009. Calls t.Fatal() on error.
+func (c *WSConn) Read() WSFrame {
+	c.t.Helper()
+	frm, err := wsReadMessage(c.r, func(WSFrame) error { return nil })
+	if err != nil {
+		if err == errWSTooBig {
+			cls := WSFrame{Opcode: WSClose, CloseCode: wsCloseTooBig}
+			_ = wsWriteFrame(c.conn, cls, true)
+		}
+		c.t.Fatal(err)
+		return WSFrame{}
+	}
+	return frm
+}
+
+// ReadText reads next message and returns it as a string. Calls t.Fatal()
+// on error or if the message is not a text message.
+func (c *WSConn) ReadText() string {
+	c.t.Helper()
+	frm := c.Read()
+	if frm.Opcode != WSText {
+		c.t.Fatalf("expected text message got %s", frm)
+		return ""
+	}
+	return string(frm.Payload)
+}
+
+// Close sends close frame with code and reason, waits for the server to
+// acknowledge it and closes the connection. Returns the close frame sent
+// by the server. Calls t.Fatal() on error.
+func (c *WSConn) Close(code int, reason string) WSFrame {
+	c.t.Helper()
+	c.WriteFrame(WSFrame{
+		Opcode:    WSClose,
+		CloseCode: code,
+		Payload:   []byte(reason),
+	})
+	defer func() { _ = c.conn.Close() }()
+	for {
+		frm := c.Read()
+		if frm.Opcode == WSClose {
+			return frm
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package testkit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// wsStep represents single step of the WebSocket conversation script.
+type wsStep struct {
+	send  bool    // Send the frame, otherwise expect it from the client.
+	frame WSFrame // Frame to send or expect.
+}
+
+// WSServer represents WebSocket test server built on top of HTTPServer.
+//
+// Every connection accepted by the server plays the scripted conversation
+// added with Expect* and Send* methods. All frames sent and received are
+// recorded. Mismatches between the script and received messages are
+// reported with t.Errorf(). The upgrade requests are recorded by the
+// underlying HTTPServer.
+type WSServer struct {
+	*HTTPServer
+	mx        sync.Mutex // Guards fields below.
+	script    []wsStep   // Conversation script.
+	frames    []WSFrame  // Recorded frames.
+	conns     int        // Number of accepted connections.
+	completed int        // Most script steps completed by a connection.
+}
+
+// NewWSServer returns new instance of WSServer configured with HTTPServer
+// options. The server will fail the test if during cleanup no connection
+// completed the conversation script.
+func NewWSServer(t T, opts ...HTTPServerOption) *WSServer {
+	t.Helper()
+	ws := &WSServer{}
+	opts = append(opts, func(tst *HTTPServer) { tst.fallback = ws })
+
+	t.Cleanup(func() {
+		t.Helper()
+		ws.mx.Lock()
+		defer ws.mx.Unlock()
+		if ws.completed != len(ws.script) {
+			t.Errorf(
+				"websocket script finished %d of %d steps",
+				ws.completed,
+				len(ws.script),
+			)
+		}
+	})
+
+	ws.HTTPServer = NewHTTPServer(t, opts...)
+	return ws
+}
+
+// URL returns WebSocket URL for the test server.
+func (ws *WSServer) URL() string {
+	return "ws" + strings.TrimPrefix(ws.HTTPServer.URL(), "http")
+}
+
+// ExpectText adds step expecting text message from the client.
+func (ws *WSServer) ExpectText(msg string) *WSServer {
+	return ws.step(false, WSFrame{Opcode: WSText, Payload: []byte(msg)})
+}
+
+// ExpectBinary adds step expecting binary message from the client.
+func (ws *WSServer) ExpectBinary(msg []byte) *WSServer {
+	return ws.step(false, WSFrame{Opcode: WSBinary, Payload: msg})
+}
+
+// ExpectClose adds step expecting close frame with code from the client.
+func (ws *WSServer) ExpectClose(code int) *WSServer {
+	return ws.step(false, WSFrame{Opcode: WSClose, CloseCode: code})
+}
+
+// SendText adds step sending text message to the client.
+func (ws *WSServer) SendText(msg string) *WSServer {
+	return ws.step(true, WSFrame{Opcode: WSText, Payload: []byte(msg)})
+}
+
+// SendBinary adds step sending binary message to the client.
+func (ws *WSServer) SendBinary(msg []byte) *WSServer {
+	return ws.step(true, WSFrame{Opcode: WSBinary, Payload: msg})
+}
+
+// SendClose adds step sending close frame with code and reason to the
+// client. The server waits for the client to acknowledge it.
+func (ws *WSServer) SendClose(code int, reason string) *WSServer {
+	return ws.step(true, WSFrame{
+		Opcode:    WSClose,
+		CloseCode: code,
+		Payload:   []byte(reason),
+	})
+}
+
+// Dial connects to the server sending upgrade request with additional
+// headers hdr which may be nil. In TLS mode it trusts the server's
+// certificate authority and in mutual TLS mode presents client certificate
+// with common name "client". See DialWS for details.
+func (ws *WSServer) Dial(hdr http.Header) *WSConn {
+	ws.t.Helper()
+	var cfg *tls.Config
+	if ws.ca != nil {
+		cfg = ws.TLSConfig()
+		if ws.mtls {
+			cfg.Certificates = []tls.Certificate{ws.ClientCert("client")}
+		}
+	}
+	return DialWSTLS(ws.t, ws.URL(), cfg, hdr)
+}
+
+// Frames returns all frames sent and received by the server.
+func (ws *WSServer) Frames() []WSFrame {
+	ws.mx.Lock()
+	defer ws.mx.Unlock()
+	return append([]WSFrame{}, ws.frames...)
+}
+
+// step adds step to the script.
+func (ws *WSServer) step(send bool, frm WSFrame) *WSServer {
+	ws.mx.Lock()
+	defer ws.mx.Unlock()
+	ws.script = append(ws.script, wsStep{send: send, frame: frm})
+	return ws
+}
+
+// ServeHTTP implements http.Handler performing WebSocket upgrade and
+// playing the script.
+func (ws *WSServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if req.Method != http.MethodGet ||
+		!strings.EqualFold(req.Header.Get("Upgrade"), "websocket") ||
+		req.Header.Get("Sec-WebSocket-Version") != "13" ||
+		key == "" {
+		ws.t.Errorf("invalid websocket handshake %s %s", req.Method, req.URL)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		ws.t.Error("websocket connection cannot be hijacked")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		ws.t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		ws.t.Error(err)
+		return
+	}
+
+	// Close the connection when the server is closing.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ws.done:
+			_ = conn.Close()
+		case <-finished:
+		}
+	}()
+
+	ws.mx.Lock()
+	wc := &wsConn{ws: ws, idx: ws.conns, conn: conn, r: brw.Reader}
+	script := append([]wsStep{}, ws.script...)
+	ws.conns++
+	ws.mx.Unlock()
+
+	wc.play(script)
+}
+
+// wsConn represents server side of the WebSocket connection.
+type wsConn struct {
+	ws   *WSServer     // The server.
+	idx  int           // Connection index.
+	conn net.Conn      // The connection.
+	r    *bufio.Reader // Connection reader.
+}
+
+// play plays the script on the connection.
+func (wc *wsConn) play(script []wsStep) {
+	for i, stp := range script {
+		if stp.send {
+			if !wc.send(stp.frame) {
+				return
+			}
+			if stp.frame.Opcode == WSClose {
+				wc.done(i + 1)
+				wc.drain(true)
+				return
+			}
+			wc.done(i + 1)
+			continue
+		}
+
+		frm, ok := wc.read()
+		if !ok {
+			wc.ws.t.Errorf(
+				"websocket connection %d step %d: expected %s got nothing",
+				wc.idx,
+				i,
+				stp.frame,
+			)
+			return
+		}
+		if !wsFrameEqual(stp.frame, frm) {
+			wc.ws.t.Errorf(
+				"websocket connection %d step %d: expected %s got %s",
+				wc.idx,
+				i,
+				stp.frame,
+				frm,
+			)
+			wc.send(WSFrame{Opcode: WSClose, CloseCode: 1002})
+			return
+		}
+		wc.done(i + 1)
+		if frm.Opcode == WSClose {
+			wc.send(WSFrame{Opcode: WSClose, CloseCode: frm.CloseCode})
+			return
+		}
+	}
+	wc.drain(false)
+}
+
+// drain reads frames until the client closes the connection. When closing
+// is true the server already sent close frame. Messages received after the
+// script finished are reported with t.Errorf().
+func (wc *wsConn) drain(closing bool) {
+	for {
+		frm, ok := wc.read()
+		if !ok {
+			return
+		}
+		if frm.Opcode == WSClose {
+			if !closing {
+				wc.send(WSFrame{Opcode: WSClose, CloseCode: frm.CloseCode})
+			}
+			return
+		}
+		if !closing {
+			wc.ws.t.Errorf(
+				"websocket connection %d: unexpected message %s",
+				wc.idx,
+				frm,
+			)
+		}
+	}
+}
+
+// read reads and records message from the client answering pings.
+// Returns false if the connection was closed or on protocol error. Messages
+// exceeding 32 MiB are answered with close frame with code 1009.
+func (wc *wsConn) read() (WSFrame, bool) {
+	for {
+		frm, err := wsReadMessage(wc.r, wc.control)
+		if err != nil {
+			if err == errWSTooBig {
+				wc.send(WSFrame{Opcode: WSClose, CloseCode: wsCloseTooBig})
+			}
+			return WSFrame{}, false
+		}
+		if frm.Opcode == WSPing || frm.Opcode == WSPong {
+			if err := wc.control(frm); err != nil {
+				return WSFrame{}, false
+			}
+			continue
+		}
+		return wc.record(frm, true), true
+	}
+}
+
+// control records control frame and answers pings.
+func (wc *wsConn) control(frm WSFrame) error {
+	wc.record(frm, true)
+	if frm.Opcode == WSPing {
+		pong := WSFrame{Opcode: WSPong, Payload: frm.Payload}
+		if !wc.send(pong) {
+			return errWSProtocol
+		}
+	}
+	return nil
+}
+
+// send writes and records frame. Returns false on error.
+func (wc *wsConn) send(frm WSFrame) bool {
+	if err := wsWriteFrame(wc.conn, frm, false); err != nil {
+		return false
+	}
+	wc.record(frm, false)
+	return true
+}
+
+// record records the frame and returns it.
+func (wc *wsConn) record(frm WSFrame, inbound bool) WSFrame {
+	frm.Conn = wc.idx
+	frm.Inbound = inbound
+	wc.ws.mx.Lock()
+	wc.ws.frames = append(wc.ws.frames, frm)
+	wc.ws.mx.Unlock()
+	return frm
+}
+
+// done records number of completed script steps.
+func (wc *wsConn) done(steps int) {
+	wc.ws.mx.Lock()
+	if steps > wc.ws.completed {
+		wc.ws.completed = steps
+	}
+	wc.ws.mx.Unlock()
+}
+
+// wsFrameEqual returns true if frames have the same opcode, payload and
+// close code. The close frame payload is compared only if exp has one.
+func wsFrameEqual(exp, got WSFrame) bool {
+	if exp.Opcode != got.Opcode || exp.CloseCode != got.CloseCode {
+		return false
+	}
+	if exp.Opcode == WSClose && len(exp.Payload) == 0 {
+		return true
+	}
+	return bytes.Equal(exp.Payload, got.Payload)
+}
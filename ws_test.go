@@ -0,0 +1,235 @@
+package testkit_test
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kit "github.com/rzajac/testkit"
+)
+
+func Test_WSServer(t *testing.T) {
+	// --- Given ---
+	ws := kit.NewWSServer(t).
+		ExpectText("hello").
+		SendText("world").
+		ExpectBinary([]byte{0, 1, 2}).
+		SendBinary([]byte{3, 4}).
+		ExpectClose(1000)
+
+	hdr := http.Header{"Authorization": []string{"Bearer token"}}
+	conn := kit.DialWS(t, ws.URL()+"/chat", hdr)
+
+	// --- When ---
+	conn.WriteText("hello")
+	got0 := conn.ReadText()
+	conn.WriteFrame(kit.WSFrame{Opcode: kit.WSPing, Payload: []byte("p")})
+	pong := conn.Read()
+	conn.WriteBinary([]byte{0, 1, 2})
+	got1 := conn.Read()
+	cls := conn.Close(1000, "bye")
+
+	// --- Then ---
+	assert.Exactly(t, "world", got0)
+	assert.Exactly(t, kit.WSPong, pong.Opcode)
+	assert.Exactly(t, []byte("p"), pong.Payload)
+	assert.Exactly(t, kit.WSBinary, got1.Opcode)
+	assert.Exactly(t, []byte{3, 4}, got1.Payload)
+	assert.Exactly(t, 1000, cls.CloseCode)
+
+	assert.Exactly(t, 1, ws.ReqCount())
+	assert.Exactly(t, "/chat", ws.Request(0).URL.Path)
+	assert.Exactly(t, "Bearer token", ws.Headers(0).Get("Authorization"))
+
+	frames := ws.Frames()
+	require.Len(t, frames, 8)
+	assert.Exactly(t, kit.WSFrame{Inbound: true, Opcode: kit.WSText, Payload: []byte("hello")}, frames[0])
+	assert.Exactly(t, kit.WSFrame{Opcode: kit.WSText, Payload: []byte("world")}, frames[1])
+	assert.Exactly(t, kit.WSPing, frames[2].Opcode)
+	assert.Exactly(t, kit.WSPong, frames[3].Opcode)
+	assert.Exactly(t, kit.WSBinary, frames[4].Opcode)
+	assert.Exactly(t, kit.WSBinary, frames[5].Opcode)
+	assert.Exactly(t, kit.WSFrame{Inbound: true, Opcode: kit.WSClose, Payload: []byte("bye"), CloseCode: 1000}, frames[6])
+	assert.Exactly(t, kit.WSFrame{Opcode: kit.WSClose, CloseCode: 1000}, frames[7])
+}
+
+func Test_WSServer_SendClose(t *testing.T) {
+	// --- Given ---
+	ws := kit.NewWSServer(t).SendText("hi").SendClose(1001, "going away")
+	conn := kit.DialWS(t, ws.URL(), nil)
+
+	// --- When ---
+	got := conn.ReadText()
+	cls := conn.Read()
+	conn.WriteFrame(kit.WSFrame{Opcode: kit.WSClose, CloseCode: 1001})
+
+	// --- Then ---
+	assert.Exactly(t, "hi", got)
+	assert.Exactly(t, kit.WSClose, cls.Opcode)
+	assert.Exactly(t, 1001, cls.CloseCode)
+	assert.Exactly(t, "going away", string(cls.Payload))
+}
+
+func Test_WSServer_mismatch(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"websocket connection %d step %d: expected %s got %s",
+		0,
+		0,
+		kit.WSFrame{Opcode: kit.WSText, Payload: []byte("hello")},
+		kit.WSFrame{Inbound: true, Opcode: kit.WSText, Payload: []byte("bye")},
+	)
+
+	ws := kit.NewWSServer(mck).ExpectText("hello")
+	conn := kit.DialWS(t, ws.URL(), nil)
+
+	// --- When ---
+	conn.WriteText("bye")
+	cls := conn.Read()
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Exactly(t, kit.WSClose, cls.Opcode)
+	assert.Exactly(t, 1002, cls.CloseCode)
+}
+
+func Test_WSServer_invalidHandshake(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On("Errorf", "invalid websocket handshake %s %s", http.MethodGet, mock.Anything)
+
+	ws := kit.NewWSServer(mck)
+
+	// --- When ---
+	rsp, err := http.Get(ws.HTTPServer.URL())
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.Exactly(t, http.StatusBadRequest, rsp.StatusCode)
+}
+
+func Test_WSServer_TLS(t *testing.T) {
+	tt := []struct {
+		testN string
+
+		opt kit.HTTPServerOption
+	}{
+		{"tls", kit.WithTLS()},
+		{"mtls", kit.WithMTLS()},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testN, func(t *testing.T) {
+			// --- Given ---
+			ws := kit.NewWSServer(t, tc.opt).
+				ExpectText("hello").
+				SendText("world").
+				ExpectClose(1000)
+
+			conn := ws.Dial(nil)
+
+			// --- When ---
+			conn.WriteText("hello")
+			got := conn.ReadText()
+			cls := conn.Close(1000, "")
+
+			// --- Then ---
+			assert.True(t, strings.HasPrefix(ws.URL(), "wss://"))
+			assert.Exactly(t, "world", got)
+			assert.Exactly(t, 1000, cls.CloseCode)
+		})
+	}
+}
+
+func Test_DialWSTLS(t *testing.T) {
+	// --- Given ---
+	ws := kit.NewWSServer(t, kit.WithTLS()).ExpectText("hello")
+
+	// --- When ---
+	conn := kit.DialWSTLS(t, ws.URL(), ws.TLSConfig(), nil)
+	conn.WriteText("hello")
+
+	// --- Then ---
+	conn.Close(1000, "")
+}
+
+func Test_DialWS_untrustedCertificate(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Helper")
+	mck.On("Fatal", mock.Anything)
+
+	ws := kit.NewWSServer(t, kit.WithTLS())
+
+	// --- When ---
+	conn := kit.DialWS(mck, ws.URL(), nil)
+
+	// --- Then ---
+	mck.AssertExpectations(t)
+	assert.Nil(t, conn)
+}
+
+func Test_WSServer_messageTooBig(t *testing.T) {
+	// --- Given ---
+	mck := &kit.TMock{}
+	mck.On("Cleanup", mock.Anything)
+	mck.On("Helper")
+	mck.On(
+		"Errorf",
+		"websocket connection %d step %d: expected %s got nothing",
+		0,
+		0,
+		kit.WSFrame{Opcode: kit.WSText, Payload: []byte("hello")},
+	)
+
+	ws := kit.NewWSServer(mck).ExpectText("hello")
+
+	u, err := url.Parse(ws.HTTPServer.URL())
+	require.NoError(t, err)
+	conn, err := net.Dial("tcp", u.Host)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ws.HTTPServer.URL(), nil)
+	require.NoError(t, err)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	require.NoError(t, req.Write(conn))
+	r := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(r, req)
+	require.NoError(t, err)
+	require.Exactly(t, http.StatusSwitchingProtocols, rsp.StatusCode)
+
+	// --- When ---
+	// Masked binary frame header announcing 1 GiB payload.
+	hdr := []byte{0x82, 0xFF, 0, 0, 0, 0, 0x40, 0, 0, 0, 1, 2, 3, 4}
+	_, err = conn.Write(hdr)
+	require.NoError(t, err)
+
+	// --- Then ---
+	got := make([]byte, 4)
+	_, err = io.ReadFull(r, got)
+	require.NoError(t, err)
+	_, _ = ioutil.ReadAll(r)
+
+	mck.AssertExpectations(t)
+	assert.Exactly(t, []byte{0x88, 0x02, 0x03, 0xF1}, got)
+}